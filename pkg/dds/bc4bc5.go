@@ -0,0 +1,254 @@
+/*
+Copyright 2017 Luke Granger-Brown
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// bc4Channel decodes a single 8-byte BC4 block (an ATI1/BC4_UNORM block,
+// the same layout as a DXT5 alpha block) into an 4x4 array of 8-bit
+// values.
+func bc4Channel(block []byte) [16]byte {
+	v0 := block[0]
+	v1 := block[1]
+	bits := binary.LittleEndian.Uint64(block[0:8]) >> 16
+
+	var palette [8]byte
+	palette[0] = v0
+	palette[1] = v1
+	if v0 > v1 {
+		for i := 2; i < 8; i++ {
+			palette[i] = byte((int(v0)*(8-i) + int(v1)*(i-1)) / 7)
+		}
+	} else {
+		for i := 2; i < 6; i++ {
+			palette[i] = byte((int(v0)*(6-i) + int(v1)*(i-1)) / 5)
+		}
+		palette[6] = 0
+		palette[7] = 255
+	}
+
+	var out [16]byte
+	for i := 0; i < 16; i++ {
+		code := (bits >> uint(i*3)) & 0x7
+		out[i] = palette[code]
+	}
+	return out
+}
+
+// decodeBC4 decodes a BC4 (ATI1/BC4_UNORM) compressed byte slice into a
+// single-channel image.
+func decodeBC4(compressed []byte, width, height int) (*image.Gray, error) {
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+
+	blockWidth := (width + 3) / 4
+	blockHeight := (height + 3) / 4
+
+	for by := 0; by < blockHeight; by++ {
+		for bx := 0; bx < blockWidth; bx++ {
+			off := (by*blockWidth + bx) * 8
+			if off+8 > len(compressed) {
+				return nil, fmt.Errorf("decoding BC4: unexpected end of data")
+			}
+			texels := bc4Channel(compressed[off : off+8])
+
+			for j := 0; j < 4; j++ {
+				py := by*4 + j
+				if py >= height {
+					continue
+				}
+				for i := 0; i < 4; i++ {
+					px := bx*4 + i
+					if px >= width {
+						continue
+					}
+					dst.SetGray(px, py, color.Gray{Y: texels[j*4+i]})
+				}
+			}
+		}
+	}
+
+	return dst, nil
+}
+
+// bc4ChannelSnorm decodes a single 8-byte BC4_SNORM block into a 4x4
+// array of signed 8-bit values. Endpoints are signed reference values in
+// [-127, 127]; the bit pattern -128 is reserved and is clamped to -127,
+// matching the D3D BC4/BC5 SNORM spec.
+func bc4ChannelSnorm(block []byte) [16]int8 {
+	v0 := int8(block[0])
+	v1 := int8(block[1])
+	if v0 == -128 {
+		v0 = -127
+	}
+	if v1 == -128 {
+		v1 = -127
+	}
+	bits := binary.LittleEndian.Uint64(block[0:8]) >> 16
+
+	var palette [8]int8
+	palette[0] = v0
+	palette[1] = v1
+	if v0 > v1 {
+		for i := 2; i < 8; i++ {
+			palette[i] = int8((int(v0)*(8-i) + int(v1)*(i-1)) / 7)
+		}
+	} else {
+		for i := 2; i < 6; i++ {
+			palette[i] = int8((int(v0)*(6-i) + int(v1)*(i-1)) / 5)
+		}
+		palette[6] = -127
+		palette[7] = 127
+	}
+
+	var out [16]int8
+	for i := 0; i < 16; i++ {
+		code := (bits >> uint(i*3)) & 0x7
+		out[i] = palette[code]
+	}
+	return out
+}
+
+// snormToUnorm rebiases a signed [-127, 127] reference value into the
+// [1, 255] range of this package's unsigned image types, so SNORM data
+// can be carried by image.Gray/image.NRGBA like its UNORM counterpart.
+func snormToUnorm(v int8) uint8 {
+	return uint8(int(v) + 128)
+}
+
+// decodeBC4Snorm decodes a BC4_SNORM compressed byte slice into a
+// single-channel image, rebiasing each signed texel via snormToUnorm.
+func decodeBC4Snorm(compressed []byte, width, height int) (*image.Gray, error) {
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+
+	blockWidth := (width + 3) / 4
+	blockHeight := (height + 3) / 4
+
+	for by := 0; by < blockHeight; by++ {
+		for bx := 0; bx < blockWidth; bx++ {
+			off := (by*blockWidth + bx) * 8
+			if off+8 > len(compressed) {
+				return nil, fmt.Errorf("decoding BC4_SNORM: unexpected end of data")
+			}
+			texels := bc4ChannelSnorm(compressed[off : off+8])
+
+			for j := 0; j < 4; j++ {
+				py := by*4 + j
+				if py >= height {
+					continue
+				}
+				for i := 0; i < 4; i++ {
+					px := bx*4 + i
+					if px >= width {
+						continue
+					}
+					dst.SetGray(px, py, color.Gray{Y: snormToUnorm(texels[j*4+i])})
+				}
+			}
+		}
+	}
+
+	return dst, nil
+}
+
+// decodeBC5 decodes a BC5 (ATI2/BC5_UNORM) compressed byte slice - two
+// stacked BC4 blocks, R then G - into an NRGBA image with B=0 and
+// A=255, matching the reconstructed-normal convention used by GPUs.
+func decodeBC5(compressed []byte, width, height int) (*image.NRGBA, error) {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	blockWidth := (width + 3) / 4
+	blockHeight := (height + 3) / 4
+
+	for by := 0; by < blockHeight; by++ {
+		for bx := 0; bx < blockWidth; bx++ {
+			off := (by*blockWidth + bx) * 16
+			if off+16 > len(compressed) {
+				return nil, fmt.Errorf("decoding BC5: unexpected end of data")
+			}
+			rTexels := bc4Channel(compressed[off : off+8])
+			gTexels := bc4Channel(compressed[off+8 : off+16])
+
+			for j := 0; j < 4; j++ {
+				py := by*4 + j
+				if py >= height {
+					continue
+				}
+				for i := 0; i < 4; i++ {
+					px := bx*4 + i
+					if px >= width {
+						continue
+					}
+					o := dst.PixOffset(px, py)
+					dst.Pix[o+0] = rTexels[j*4+i]
+					dst.Pix[o+1] = gTexels[j*4+i]
+					dst.Pix[o+2] = 0
+					dst.Pix[o+3] = 255
+				}
+			}
+		}
+	}
+
+	return dst, nil
+}
+
+// decodeBC5Snorm decodes a BC5_SNORM compressed byte slice - two stacked
+// BC4_SNORM blocks, R then G - into an NRGBA image with B=0 and A=255,
+// rebiasing each signed channel via snormToUnorm. BC5_SNORM is the
+// conventional encoding for tangent-space normal maps, so the signed
+// ramp matters here in practice, not just at the edges.
+func decodeBC5Snorm(compressed []byte, width, height int) (*image.NRGBA, error) {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	blockWidth := (width + 3) / 4
+	blockHeight := (height + 3) / 4
+
+	for by := 0; by < blockHeight; by++ {
+		for bx := 0; bx < blockWidth; bx++ {
+			off := (by*blockWidth + bx) * 16
+			if off+16 > len(compressed) {
+				return nil, fmt.Errorf("decoding BC5_SNORM: unexpected end of data")
+			}
+			rTexels := bc4ChannelSnorm(compressed[off : off+8])
+			gTexels := bc4ChannelSnorm(compressed[off+8 : off+16])
+
+			for j := 0; j < 4; j++ {
+				py := by*4 + j
+				if py >= height {
+					continue
+				}
+				for i := 0; i < 4; i++ {
+					px := bx*4 + i
+					if px >= width {
+						continue
+					}
+					o := dst.PixOffset(px, py)
+					dst.Pix[o+0] = snormToUnorm(rTexels[j*4+i])
+					dst.Pix[o+1] = snormToUnorm(gTexels[j*4+i])
+					dst.Pix[o+2] = 0
+					dst.Pix[o+3] = 255
+				}
+			}
+		}
+	}
+
+	return dst, nil
+}
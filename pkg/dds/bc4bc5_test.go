@@ -0,0 +1,81 @@
+/*
+Copyright 2017 Luke Granger-Brown
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import "testing"
+
+// TestBC4ChannelGolden pins the v0=200, v1=50 8-value ramp to the
+// reference S3TC/BC4 interpolants: (v0*(8-i)+v1*(i-1))/7. This is the
+// exact off-by-one regression the ramp previously shipped with, so the
+// expected values below (index 2 -> 178, index 7 -> 71) catch a
+// reintroduction of the (7-i)/(i-1) formula.
+func TestBC4ChannelGolden(t *testing.T) {
+	block := [8]byte{
+		200, 50, // v0, v1 (v0 > v1 selects the 8-value ramp)
+		0x88, 0x0E, 0x00, 0x00, 0x00, 0x00, // codes 0,1,2,7 then index 0
+	}
+	texels := bc4Channel(block[:])
+
+	want := [16]byte{
+		200, 50, 178, 71,
+		200, 200, 200, 200,
+		200, 200, 200, 200,
+		200, 200, 200, 200,
+	}
+	if texels != want {
+		t.Errorf("bc4Channel(%v) = %v, want %v", block, texels, want)
+	}
+}
+
+// TestBC4ChannelSnormGolden pins the v0=100, v1=-50 signed 8-value ramp
+// to the same interpolant formula as the UNORM ramp, but over signed
+// reference values: (v0*(8-i)+v1*(i-1))/7.
+func TestBC4ChannelSnormGolden(t *testing.T) {
+	block := [8]byte{
+		100, 0xCE, // v0=100, v1=-50 as int8 (v0 > v1 selects the 8-value ramp)
+		0x88, 0x0E, 0x00, 0x00, 0x00, 0x00, // codes 0,1,2,7 then index 0
+	}
+	texels := bc4ChannelSnorm(block[:])
+
+	want := [16]int8{
+		100, -50, 78, -28,
+		100, 100, 100, 100,
+		100, 100, 100, 100,
+		100, 100, 100, 100,
+	}
+	if texels != want {
+		t.Errorf("bc4ChannelSnorm(%v) = %v, want %v", block, texels, want)
+	}
+}
+
+// TestBC4ChannelSnormClampsReservedValue checks that the reserved -128
+// encoding is clamped to -127 before it enters the ramp, per the D3D
+// BC4/BC5 SNORM spec, rather than being used verbatim.
+func TestBC4ChannelSnormClampsReservedValue(t *testing.T) {
+	block := [8]byte{
+		0x80, 50, // v0 = -128 (reserved, clamps to -127), v1 = 50
+		0x88, 0x0E, 0x00, 0x00, 0x00, 0x00, // codes 0,1,2,7 then index 0
+	}
+	texels := bc4ChannelSnorm(block[:])
+
+	if texels[0] != -127 {
+		t.Errorf("bc4ChannelSnorm clamped v0 = %d, want -127", texels[0])
+	}
+	if texels[2] != -91 {
+		t.Errorf("bc4ChannelSnorm ramp with clamped v0: index 2 = %d, want -91", texels[2])
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2017 Luke Granger-Brown
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+// bc6hModeInfo describes the wire layout of one of the 14 valid BC6H
+// block modes, per the D3D11/DXGI BC6H specification.
+type bc6hModeInfo struct {
+	modeBits    uint32  // the mode selector value
+	modeLen     uint    // number of mode-selector bits (2 or 5)
+	subsets     int     // 1 or 2
+	endBits     [3]uint // base (subset 0, endpoint 0) bits per component
+	deltaBits   [3]uint // bits per component for the other 1-3 endpoints (signed deltas when transformed)
+	transformed bool    // endpoints after the first are signed deltas
+}
+
+// bc6hModes enumerates the 14 valid BC6H modes, indexed by their
+// canonical mode number (0-13); the four reserved 5-bit encodings
+// (0x13, 0x17, 0x1b, 0x1f) decode to black per the spec.
+var bc6hModes = [14]bc6hModeInfo{
+	{modeBits: 0x00, modeLen: 2, subsets: 2, endBits: [3]uint{10, 10, 10}, deltaBits: [3]uint{5, 5, 5}, transformed: true},
+	{modeBits: 0x01, modeLen: 2, subsets: 2, endBits: [3]uint{7, 7, 7}, deltaBits: [3]uint{6, 6, 6}, transformed: true},
+	{modeBits: 0x02, modeLen: 5, subsets: 2, endBits: [3]uint{11, 11, 11}, deltaBits: [3]uint{5, 4, 4}, transformed: true},
+	{modeBits: 0x06, modeLen: 5, subsets: 2, endBits: [3]uint{11, 11, 11}, deltaBits: [3]uint{4, 5, 4}, transformed: true},
+	{modeBits: 0x0a, modeLen: 5, subsets: 2, endBits: [3]uint{11, 11, 11}, deltaBits: [3]uint{4, 4, 5}, transformed: true},
+	{modeBits: 0x0e, modeLen: 5, subsets: 2, endBits: [3]uint{9, 9, 9}, deltaBits: [3]uint{5, 5, 5}, transformed: true},
+	{modeBits: 0x12, modeLen: 5, subsets: 2, endBits: [3]uint{8, 8, 8}, deltaBits: [3]uint{6, 5, 5}, transformed: true},
+	{modeBits: 0x16, modeLen: 5, subsets: 2, endBits: [3]uint{8, 8, 8}, deltaBits: [3]uint{5, 6, 5}, transformed: true},
+	{modeBits: 0x1a, modeLen: 5, subsets: 2, endBits: [3]uint{8, 8, 8}, deltaBits: [3]uint{5, 5, 6}, transformed: true},
+	{modeBits: 0x1e, modeLen: 5, subsets: 2, endBits: [3]uint{6, 6, 6}, deltaBits: [3]uint{6, 6, 6}, transformed: false},
+	{modeBits: 0x03, modeLen: 5, subsets: 1, endBits: [3]uint{10, 10, 10}, deltaBits: [3]uint{10, 10, 10}, transformed: false},
+	{modeBits: 0x07, modeLen: 5, subsets: 1, endBits: [3]uint{11, 11, 11}, deltaBits: [3]uint{9, 9, 9}, transformed: true},
+	{modeBits: 0x0b, modeLen: 5, subsets: 1, endBits: [3]uint{12, 12, 12}, deltaBits: [3]uint{8, 8, 8}, transformed: true},
+	{modeBits: 0x0f, modeLen: 5, subsets: 1, endBits: [3]uint{16, 16, 16}, deltaBits: [3]uint{4, 4, 4}, transformed: true},
+}
+
+// bc6hPartitionTable2 gives, for each of the 32 two-subset BC6H
+// partition patterns, the subset index (0 or 1) of each of the 16
+// texels in raster order. BC6H reuses the same shape language as BC7's
+// two-subset table, truncated to the 32 patterns BC6H's 5-bit partition
+// field can select.
+var bc6hPartitionTable2 = bc7PartitionTable2[:32]
+
+// bc6hFixupIndex2 gives the texel index whose index bit is stored
+// implicitly (always 0) for the second subset in a given two-subset
+// partition, matching bc7FixupIndex2.
+var bc6hFixupIndex2 = bc7FixupIndex2[:32]
@@ -0,0 +1,316 @@
+/*
+Copyright 2017 Luke Granger-Brown
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"fmt"
+	"image"
+)
+
+// bc7BitReader reads a 128-bit BC7 block LSB-first.
+type bc7BitReader struct {
+	block [16]byte
+	pos   uint
+}
+
+func (r *bc7BitReader) read(n uint) uint32 {
+	var v uint32
+	for i := uint(0); i < n; i++ {
+		bit := r.pos + i
+		byteIdx := bit / 8
+		bitIdx := bit % 8
+		if int(byteIdx) < len(r.block) && r.block[byteIdx]&(1<<bitIdx) != 0 {
+			v |= 1 << i
+		}
+	}
+	r.pos += n
+	return v
+}
+
+// bc7Weights2/3/4 are the interpolation weights for 2-, 3- and 4-bit
+// index fields (D3D BC7 spec table).
+var bc7Weights2 = [4]int{0, 21, 43, 64}
+var bc7Weights3 = [8]int{0, 9, 18, 27, 37, 46, 55, 64}
+var bc7Weights4 = [16]int{0, 4, 9, 13, 17, 21, 26, 30, 34, 38, 43, 47, 51, 55, 60, 64}
+
+func weightsFor(bits uint) []int {
+	switch bits {
+	case 2:
+		return bc7Weights2[:]
+	case 3:
+		return bc7Weights3[:]
+	default:
+		return bc7Weights4[:]
+	}
+}
+
+// expandBC7Component upsamples a bits-wide (optionally p-bit-extended)
+// component to 8 bits via the spec's bit-replication rule.
+func expandBC7Component(v uint32, bits uint) uint8 {
+	if bits >= 8 {
+		return uint8(v)
+	}
+	v <<= 8 - bits
+	return uint8(v | (v >> bits))
+}
+
+// decodeBC7 decodes a BC7 compressed byte slice into an RGBA image.
+func decodeBC7(compressed []byte, width, height int) (*image.RGBA, error) {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	blockWidth := (width + 3) / 4
+	blockHeight := (height + 3) / 4
+
+	for by := 0; by < blockHeight; by++ {
+		for bx := 0; bx < blockWidth; bx++ {
+			off := (by*blockWidth + bx) * 16
+			if off+16 > len(compressed) {
+				return nil, fmt.Errorf("decoding BC7: unexpected end of data")
+			}
+			var block [16]byte
+			copy(block[:], compressed[off:off+16])
+
+			texels := decodeBC7Block(block)
+
+			for j := 0; j < 4; j++ {
+				py := by*4 + j
+				if py >= height {
+					continue
+				}
+				for i := 0; i < 4; i++ {
+					px := bx*4 + i
+					if px >= width {
+						continue
+					}
+					o := dst.PixOffset(px, py)
+					c := texels[j*4+i]
+					dst.Pix[o+0] = c.R
+					dst.Pix[o+1] = c.G
+					dst.Pix[o+2] = c.B
+					dst.Pix[o+3] = c.A
+				}
+			}
+		}
+	}
+
+	return dst, nil
+}
+
+func decodeBC7Block(block [16]byte) [16]rgba8 {
+	r := &bc7BitReader{block: block}
+
+	modeIdx := -1
+	for i := 0; i < 8; i++ {
+		if r.read(1) == 1 {
+			modeIdx = i
+			break
+		}
+	}
+	if modeIdx < 0 {
+		// Reserved mode byte (all-zero mode field): spec mandates
+		// decoding to transparent black.
+		var out [16]rgba8
+		return out
+	}
+	mode := bc7Modes[modeIdx]
+
+	partition := uint32(0)
+	if mode.partitionBits > 0 {
+		partition = r.read(mode.partitionBits)
+	}
+
+	rotation := uint32(0)
+	if mode.rotationBits > 0 {
+		rotation = r.read(mode.rotationBits)
+	}
+
+	idxSel := uint32(0)
+	if modeIdx == 4 {
+		idxSel = r.read(1)
+	}
+
+	numEndpoints := mode.subsets * 2
+	var colors [6][4]uint32 // [endpoint][R,G,B,A]
+
+	for c := 0; c < 3; c++ {
+		for e := 0; e < numEndpoints; e++ {
+			colors[e][c] = r.read(mode.colorBits)
+		}
+	}
+	if mode.aBits > 0 {
+		for e := 0; e < numEndpoints; e++ {
+			colors[e][3] = r.read(mode.aBits)
+		}
+	}
+
+	componentBits := mode.colorBits
+	alphaBits := mode.aBits
+	if mode.pBits == 1 {
+		pbits := make([]uint32, mode.subsets)
+		for s := range pbits {
+			pbits[s] = r.read(1)
+		}
+		for e := 0; e < numEndpoints; e++ {
+			p := pbits[e/2]
+			for c := 0; c < 3; c++ {
+				colors[e][c] = colors[e][c]<<1 | p
+			}
+			if mode.aBits > 0 {
+				colors[e][3] = colors[e][3]<<1 | p
+			}
+		}
+		componentBits++
+		if mode.aBits > 0 {
+			alphaBits++
+		}
+	} else if mode.pBits == 2 {
+		for e := 0; e < numEndpoints; e++ {
+			p := r.read(1)
+			for c := 0; c < 3; c++ {
+				colors[e][c] = colors[e][c]<<1 | p
+			}
+			if mode.aBits > 0 {
+				colors[e][3] = colors[e][3]<<1 | p
+			}
+		}
+		componentBits++
+		if mode.aBits > 0 {
+			alphaBits++
+		}
+	}
+
+	var endpoints [6][4]uint8
+	for e := 0; e < numEndpoints; e++ {
+		for c := 0; c < 3; c++ {
+			endpoints[e][c] = expandBC7Component(colors[e][c], componentBits)
+		}
+		if mode.aBits > 0 {
+			endpoints[e][3] = expandBC7Component(colors[e][3], alphaBits)
+		} else {
+			endpoints[e][3] = 255
+		}
+	}
+
+	var partTable [16]uint8
+	var anchor2, anchor3a, anchor3b uint8
+	switch mode.subsets {
+	case 2:
+		partTable = bc7PartitionTable2[partition]
+		anchor2 = bc7FixupIndex2[partition]
+	case 3:
+		partTable = bc7PartitionTable3[partition]
+		anchor3a = bc7FixupIndex3a[partition]
+		anchor3b = bc7FixupIndex3b[partition]
+	}
+
+	isAnchor := func(i int, subset int) bool {
+		switch mode.subsets {
+		case 1:
+			return i == 0
+		case 2:
+			return i == 0 || (subset == 1 && uint8(i) == anchor2)
+		case 3:
+			switch subset {
+			case 0:
+				return i == 0
+			case 1:
+				return uint8(i) == anchor3a
+			default:
+				return uint8(i) == anchor3b
+			}
+		}
+		return false
+	}
+
+	primary := make([]uint32, 16)
+	weights1 := weightsFor(mode.indexBits)
+	for i := 0; i < 16; i++ {
+		subset := 0
+		if mode.subsets > 1 {
+			subset = int(partTable[i])
+		}
+		bits := mode.indexBits
+		if isAnchor(i, subset) {
+			bits--
+		}
+		primary[i] = r.read(bits)
+	}
+
+	var secondary []uint32
+	var weights2 []int
+	if mode.index2Bits > 0 {
+		secondary = make([]uint32, 16)
+		weights2 = weightsFor(mode.index2Bits)
+		for i := 0; i < 16; i++ {
+			bits := mode.index2Bits
+			if i == 0 {
+				bits--
+			}
+			secondary[i] = r.read(bits)
+		}
+	}
+
+	var out [16]rgba8
+	for i := 0; i < 16; i++ {
+		subset := 0
+		if mode.subsets > 1 {
+			subset = int(partTable[i])
+		}
+		e0, e1 := endpoints[subset*2], endpoints[subset*2+1]
+
+		colorIdx, alphaIdx := primary[i], primary[i]
+		colorWeights, alphaWeights := weights1, weights1
+		if secondary != nil {
+			if idxSel == 0 {
+				alphaIdx, alphaWeights = secondary[i], weights2
+			} else {
+				colorIdx, colorWeights = secondary[i], weights2
+			}
+		}
+
+		var c rgba8
+		c.R = interpolateBC7(e0[0], e1[0], colorWeights[colorIdx])
+		c.G = interpolateBC7(e0[1], e1[1], colorWeights[colorIdx])
+		c.B = interpolateBC7(e0[2], e1[2], colorWeights[colorIdx])
+		if mode.aBits > 0 || secondary != nil {
+			c.A = interpolateBC7(e0[3], e1[3], alphaWeights[alphaIdx])
+		} else {
+			c.A = 255
+		}
+
+		switch rotation {
+		case 1:
+			c.R, c.A = c.A, c.R
+		case 2:
+			c.G, c.A = c.A, c.G
+		case 3:
+			c.B, c.A = c.A, c.B
+		}
+
+		out[i] = c
+	}
+
+	return out
+}
+
+type rgba8 struct {
+	R, G, B, A uint8
+}
+
+func interpolateBC7(e0, e1 uint8, weight int) uint8 {
+	return uint8((int(e0)*(64-weight) + int(e1)*weight + 32) >> 6)
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2017 Luke Granger-Brown
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import "testing"
+
+// bc7TestBitWriter packs fields LSB-first into a 128-bit block, mirroring
+// bc7BitReader, so these tests can assemble blocks field-by-field instead
+// of hand-computing raw bytes.
+type bc7TestBitWriter struct {
+	block [16]byte
+	pos   uint
+}
+
+func (w *bc7TestBitWriter) write(v uint32, n uint) {
+	for i := uint(0); i < n; i++ {
+		if v&(1<<i) != 0 {
+			bit := w.pos + i
+			w.block[bit/8] |= 1 << (bit % 8)
+		}
+	}
+	w.pos += n
+}
+
+// TestDecodeBC7Block2Subset builds a mode 1 (2-subset) block using
+// partition 0 and checks that texels are interpolated from the correct
+// subset's endpoints, including at the subset-1 anchor texel (15) that
+// bc7FixupIndex2[0] identifies and which therefore has its index MSB
+// implicit rather than coded.
+func TestDecodeBC7Block2Subset(t *testing.T) {
+	w := &bc7TestBitWriter{}
+	w.write(0, 1) // mode bit 0: not selected
+	w.write(1, 1) // mode bit 1: selects mode 1
+	w.write(0, 6) // partition = 0
+
+	raw := [4]uint32{63, 0, 63, 0} // e0, e1 (subset 0); e2, e3 (subset 1)
+	for c := 0; c < 3; c++ {
+		for _, v := range raw {
+			w.write(v, 6)
+		}
+	}
+	w.write(1, 1) // p-bit for subset 0
+	w.write(0, 1) // p-bit for subset 1
+
+	// index i=0 and i=15 are the global and subset-1 anchors for
+	// partition 0 (bc7FixupIndex2[0] == 15), so they read 2 bits; every
+	// other texel reads the full 3.
+	idx := [16]struct {
+		v    uint32
+		bits uint
+	}{
+		{2, 2}, {5, 3}, {3, 3}, {0, 3},
+		{0, 3}, {0, 3}, {0, 3}, {0, 3},
+		{0, 3}, {0, 3}, {0, 3}, {0, 3},
+		{0, 3}, {0, 3}, {0, 3}, {1, 2},
+	}
+	for _, e := range idx {
+		w.write(e.v, e.bits)
+	}
+
+	texels := decodeBC7Block(w.block)
+
+	want := [16]uint8{
+		184, 73, 146, 253,
+		255, 255, 253, 253,
+		255, 255, 253, 253,
+		255, 255, 253, 217,
+	}
+	for i, wantV := range want {
+		c := texels[i]
+		if c.R != wantV || c.G != wantV || c.B != wantV || c.A != 255 {
+			t.Errorf("texel %d = %+v, want grey %d, alpha 255", i, c, wantV)
+		}
+	}
+}
+
+// TestDecodeBC7Block3Subset builds a mode 0 (3-subset) block using
+// partition 0 and checks that each of the three subsets picks up its own
+// endpoint pair at the anchors bc7FixupIndex3a[0]/bc7FixupIndex3b[0]
+// identify (texels 2 and 7).
+func TestDecodeBC7Block3Subset(t *testing.T) {
+	w := &bc7TestBitWriter{}
+	w.write(1, 1) // mode bit 0: selects mode 0
+	w.write(0, 4) // partition = 0
+
+	raw := [6]uint32{15, 0, 15, 0, 15, 0} // e0,e1 (subset0); e2,e3 (subset1); e4,e5 (subset2)
+	for c := 0; c < 3; c++ {
+		for _, v := range raw {
+			w.write(v, 4)
+		}
+	}
+	// mode 0 has a unique p-bit per endpoint; each subset's pair is
+	// given the same p-bit here.
+	w.write(1, 1) // p-bit for e0 (subset 0)
+	w.write(1, 1) // p-bit for e1 (subset 0)
+	w.write(0, 1) // p-bit for e2 (subset 1)
+	w.write(0, 1) // p-bit for e3 (subset 1)
+	w.write(1, 1) // p-bit for e4 (subset 2)
+	w.write(1, 1) // p-bit for e5 (subset 2)
+
+	// i=0 is the global anchor, i=2 is bc7FixupIndex3a[0] (subset 1's
+	// anchor), i=7 is bc7FixupIndex3b[0] (subset 2's anchor); all three
+	// read 2 bits instead of 3.
+	idx := [16]struct {
+		v    uint32
+		bits uint
+	}{
+		{2, 2}, {0, 3}, {1, 2}, {0, 3},
+		{0, 3}, {0, 3}, {0, 3}, {1, 2},
+		{0, 3}, {0, 3}, {0, 3}, {0, 3},
+		{0, 3}, {0, 3}, {0, 3}, {0, 3},
+	}
+	for _, e := range idx {
+		w.write(e.v, e.bits)
+	}
+
+	texels := decodeBC7Block(w.block)
+
+	want := [16]uint8{
+		186, 255, 212, 247,
+		255, 255, 247, 220,
+		255, 255, 255, 255,
+		255, 255, 255, 255,
+	}
+	for i, wantV := range want {
+		c := texels[i]
+		if c.R != wantV || c.G != wantV || c.B != wantV || c.A != 255 {
+			t.Errorf("texel %d = %+v, want grey %d, alpha 255", i, c, wantV)
+		}
+	}
+}
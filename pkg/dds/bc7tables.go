@@ -0,0 +1,205 @@
+/*
+Copyright 2017 Luke Granger-Brown
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+// bc7ModeInfo describes the wire layout of one of BC7's 8 modes, per the
+// D3D11/DXGI BC7 specification: how many subsets and partition-table
+// bits it has, the endpoint/p-bit precision, and the index bit counts.
+type bc7ModeInfo struct {
+	subsets       int
+	partitionBits uint
+	rotationBits  uint
+	colorBits     uint // bits per component per endpoint
+	aBits         uint // bits per alpha endpoint (0 if no alpha)
+	pBits         uint // 0 = none, 1 = shared p-bit, 2 = unique p-bit per endpoint
+	indexBits     uint
+	index2Bits    uint // 0 if there's no secondary index (no index selection bit)
+}
+
+// bc7Modes is indexed by the mode number, read as a unary field from the
+// LSB of the block (mode m occupies bit m, the lowest set bit selects the mode).
+var bc7Modes = [8]bc7ModeInfo{
+	{subsets: 3, partitionBits: 4, colorBits: 4, pBits: 2, indexBits: 3},
+	{subsets: 2, partitionBits: 6, colorBits: 6, pBits: 1, indexBits: 3},
+	{subsets: 3, partitionBits: 6, colorBits: 5, pBits: 0, indexBits: 2},
+	{subsets: 2, partitionBits: 6, colorBits: 7, pBits: 2, indexBits: 2},
+	{subsets: 1, partitionBits: 0, rotationBits: 2, colorBits: 5, aBits: 6, pBits: 0, indexBits: 2, index2Bits: 3},
+	{subsets: 1, partitionBits: 0, rotationBits: 2, colorBits: 7, aBits: 8, pBits: 0, indexBits: 2, index2Bits: 2},
+	{subsets: 1, partitionBits: 0, colorBits: 7, aBits: 7, pBits: 2, indexBits: 4},
+	{subsets: 2, partitionBits: 6, colorBits: 5, aBits: 5, pBits: 2, indexBits: 2},
+}
+
+// bc7PartitionTable2 gives, for each of the 64 two-subset BC7 partition
+// patterns, the subset index (0 or 1) of each of the 16 texels in
+// raster order; texel 0 always belongs to subset 0. These are the
+// reference D3D11 BC7 partition masks (the same 64 patterns also used,
+// truncated to the first 32, by BC6H's two-subset modes).
+var bc7PartitionTable2 = [64][16]uint8{
+	{0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1},
+	{0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 1},
+	{0, 1, 1, 1, 0, 1, 1, 1, 0, 1, 1, 1, 0, 1, 1, 1},
+	{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 1, 1, 1},
+	{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 1, 1},
+	{0, 0, 1, 1, 0, 1, 1, 1, 0, 1, 1, 1, 1, 1, 1, 1},
+	{0, 0, 0, 1, 0, 0, 1, 1, 0, 1, 1, 1, 1, 1, 1, 1},
+	{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 1, 1, 0, 1, 1, 1},
+	{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 1, 1},
+	{0, 0, 1, 1, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+	{0, 0, 0, 0, 0, 0, 0, 1, 0, 1, 1, 1, 1, 1, 1, 1},
+	{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 1, 1, 1},
+	{0, 0, 0, 1, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+	{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1},
+	{0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+	{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1},
+	{0, 0, 0, 0, 1, 0, 0, 0, 1, 1, 1, 0, 1, 1, 1, 1},
+	{0, 1, 1, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0},
+	{0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 1, 1, 1, 0},
+	{0, 1, 1, 1, 0, 0, 1, 1, 0, 0, 0, 1, 0, 0, 0, 0},
+	{0, 0, 1, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0},
+	{0, 0, 0, 0, 1, 0, 0, 0, 1, 1, 0, 0, 1, 1, 1, 0},
+	{0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 1, 1, 0, 0},
+	{0, 1, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 0, 1},
+	{0, 0, 1, 1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0},
+	{0, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 1, 1, 0, 0},
+	{0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0},
+	{0, 0, 1, 1, 0, 1, 1, 0, 0, 1, 1, 0, 1, 1, 0, 0},
+	{0, 0, 0, 1, 0, 1, 1, 1, 1, 1, 1, 0, 1, 0, 0, 0},
+	{0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0},
+	{0, 1, 1, 1, 0, 0, 0, 1, 1, 0, 0, 0, 1, 1, 1, 0},
+	{0, 0, 1, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 1, 0, 0},
+	{0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1},
+	{0, 0, 0, 0, 1, 1, 1, 1, 0, 0, 0, 0, 1, 1, 1, 1},
+	{0, 1, 0, 1, 1, 0, 1, 0, 0, 1, 0, 1, 1, 0, 1, 0},
+	{0, 0, 1, 1, 0, 0, 1, 1, 1, 1, 0, 0, 1, 1, 0, 0},
+	{0, 0, 1, 1, 1, 1, 0, 0, 0, 0, 1, 1, 1, 1, 0, 0},
+	{0, 1, 0, 1, 0, 1, 0, 1, 1, 0, 1, 0, 1, 0, 1, 0},
+	{0, 1, 1, 0, 1, 0, 0, 1, 0, 1, 1, 0, 1, 0, 0, 1},
+	{0, 1, 0, 1, 1, 0, 1, 0, 1, 0, 1, 0, 0, 1, 0, 1},
+	{0, 1, 1, 1, 0, 0, 1, 1, 1, 1, 0, 0, 1, 1, 1, 0},
+	{0, 0, 0, 1, 0, 0, 1, 1, 1, 1, 0, 0, 1, 0, 0, 0},
+	{0, 0, 1, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 1, 0, 0},
+	{0, 0, 1, 1, 1, 0, 1, 1, 1, 1, 0, 1, 1, 1, 0, 0},
+	{0, 1, 1, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0, 1, 1, 0},
+	{0, 0, 1, 1, 1, 1, 0, 0, 1, 1, 0, 0, 0, 0, 1, 1},
+	{0, 1, 1, 0, 0, 1, 1, 0, 1, 0, 0, 1, 1, 0, 0, 1},
+	{0, 0, 0, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 0, 0, 0},
+	{0, 1, 0, 0, 1, 1, 1, 0, 0, 1, 0, 0, 0, 0, 0, 0},
+	{0, 0, 1, 0, 0, 1, 1, 1, 0, 0, 1, 0, 0, 0, 0, 0},
+	{0, 0, 0, 0, 0, 0, 1, 0, 0, 1, 1, 1, 0, 0, 1, 0},
+	{0, 0, 0, 0, 0, 1, 0, 0, 1, 1, 1, 0, 0, 1, 0, 0},
+	{0, 1, 1, 0, 1, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 1},
+	{0, 0, 1, 1, 0, 1, 1, 0, 1, 1, 0, 0, 1, 0, 0, 1},
+	{0, 1, 1, 0, 0, 0, 1, 1, 1, 0, 0, 1, 1, 1, 0, 0},
+	{0, 0, 1, 1, 1, 0, 0, 1, 1, 1, 0, 0, 0, 1, 1, 0},
+	{0, 1, 1, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 0, 0, 1},
+	{0, 1, 1, 0, 0, 0, 1, 1, 0, 0, 1, 1, 1, 0, 0, 1},
+	{0, 1, 1, 1, 1, 1, 1, 0, 1, 0, 0, 0, 0, 0, 0, 1},
+	{0, 0, 0, 1, 1, 0, 0, 0, 1, 1, 1, 0, 0, 1, 1, 1},
+	{0, 0, 0, 0, 1, 1, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1},
+	{0, 0, 1, 1, 0, 0, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0},
+	{0, 0, 1, 0, 0, 0, 1, 0, 1, 1, 1, 0, 1, 1, 1, 0},
+	{0, 1, 0, 0, 0, 1, 0, 0, 0, 1, 1, 1, 0, 1, 1, 1},
+}
+
+var bc7FixupIndex2 = [64]uint8{
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 2, 8, 2, 2, 8, 8, 15, 2, 8, 2, 2, 8, 8, 2, 2,
+	15, 15, 6, 8, 2, 8, 15, 15, 2, 8, 2, 2, 2, 15, 15, 6,
+	6, 2, 6, 8, 15, 15, 2, 2, 15, 15, 15, 15, 15, 2, 2, 15,
+}
+
+// bc7PartitionTable3 gives, for each of the 64 three-subset BC7
+// partition patterns, the subset index (0, 1 or 2) of each of the 16
+// texels in raster order; texel 0 always belongs to subset 0, and every
+// partition uses all three subsets.
+var bc7PartitionTable3 = [64][16]uint8{
+	{0, 0, 1, 1, 0, 0, 1, 2, 0, 2, 2, 2, 0, 2, 2, 2},
+	{0, 0, 2, 2, 0, 0, 1, 2, 0, 1, 1, 1, 0, 1, 1, 1},
+	{0, 0, 0, 0, 2, 2, 1, 1, 2, 1, 1, 1, 1, 1, 1, 1},
+	{0, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+	{0, 0, 0, 0, 0, 1, 1, 1, 0, 1, 2, 2, 2, 2, 2, 2},
+	{0, 0, 0, 0, 0, 0, 1, 2, 0, 1, 1, 2, 1, 1, 1, 2},
+	{0, 0, 2, 2, 0, 0, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1},
+	{0, 0, 0, 1, 0, 0, 1, 1, 2, 2, 1, 1, 2, 2, 1, 1},
+	{0, 0, 2, 2, 1, 1, 1, 2, 1, 1, 1, 2, 1, 1, 1, 2},
+	{0, 0, 0, 0, 0, 2, 2, 2, 1, 2, 2, 2, 1, 1, 1, 1},
+	{0, 0, 1, 1, 2, 1, 1, 1, 2, 1, 1, 1, 2, 1, 1, 1},
+	{0, 0, 2, 2, 0, 2, 2, 2, 0, 1, 2, 2, 1, 1, 1, 1},
+	{0, 0, 0, 0, 0, 0, 2, 1, 0, 2, 2, 1, 0, 1, 1, 1},
+	{0, 1, 1, 1, 0, 1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2},
+	{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 2, 2, 2},
+	{0, 2, 1, 1, 0, 1, 1, 1, 0, 1, 1, 1, 0, 1, 1, 1},
+	{0, 0, 0, 0, 0, 1, 1, 1, 2, 1, 1, 1, 2, 2, 2, 2},
+	{0, 0, 0, 1, 0, 2, 1, 1, 2, 2, 1, 1, 2, 2, 1, 1},
+	{0, 0, 2, 2, 0, 1, 1, 2, 0, 1, 1, 2, 0, 1, 1, 2},
+	{0, 0, 2, 2, 0, 1, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1},
+	{0, 2, 2, 2, 0, 2, 1, 1, 0, 1, 1, 1, 1, 1, 1, 1},
+	{0, 0, 0, 0, 0, 0, 0, 0, 2, 1, 1, 1, 2, 1, 1, 1},
+	{0, 0, 2, 2, 0, 0, 1, 1, 0, 1, 1, 1, 0, 1, 1, 1},
+	{0, 0, 0, 2, 0, 0, 1, 2, 0, 1, 1, 1, 0, 1, 1, 1},
+	{0, 0, 1, 1, 2, 1, 1, 1, 2, 1, 1, 1, 2, 1, 1, 1},
+	{0, 0, 1, 1, 2, 1, 1, 1, 2, 1, 1, 1, 2, 1, 1, 1},
+	{0, 0, 0, 0, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1},
+	{0, 0, 0, 0, 0, 0, 0, 1, 2, 2, 1, 1, 2, 1, 1, 1},
+	{0, 0, 2, 2, 0, 0, 2, 2, 0, 1, 1, 2, 1, 1, 1, 1},
+	{0, 0, 2, 2, 0, 0, 2, 2, 1, 1, 2, 2, 1, 1, 1, 1},
+	{0, 0, 0, 0, 0, 2, 2, 2, 1, 2, 2, 2, 1, 1, 1, 1},
+	{0, 0, 1, 1, 2, 2, 1, 1, 2, 2, 1, 1, 2, 2, 1, 1},
+	{0, 0, 1, 1, 2, 2, 1, 1, 2, 2, 1, 1, 2, 2, 1, 1},
+	{0, 0, 0, 2, 0, 0, 2, 2, 0, 0, 1, 1, 0, 1, 1, 1},
+	{0, 0, 1, 1, 0, 1, 1, 1, 0, 1, 1, 2, 0, 2, 2, 2},
+	{0, 0, 2, 1, 0, 2, 2, 1, 0, 2, 2, 1, 0, 2, 2, 1},
+	{0, 0, 0, 1, 0, 2, 1, 1, 2, 2, 2, 1, 2, 2, 2, 1},
+	{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 2, 2, 2},
+	{0, 2, 1, 1, 0, 2, 1, 1, 0, 2, 1, 1, 0, 2, 1, 1},
+	{0, 0, 1, 1, 0, 0, 1, 1, 0, 2, 2, 2, 2, 2, 2, 2},
+	{0, 0, 2, 2, 0, 0, 2, 2, 1, 1, 2, 2, 1, 1, 1, 1},
+	{0, 0, 2, 2, 0, 0, 2, 1, 0, 1, 1, 1, 0, 1, 1, 1},
+	{0, 0, 2, 2, 0, 0, 2, 2, 0, 1, 1, 2, 1, 1, 1, 1},
+	{0, 1, 1, 1, 0, 1, 1, 2, 0, 1, 2, 2, 0, 1, 2, 2},
+	{0, 0, 1, 1, 0, 1, 1, 1, 2, 2, 1, 1, 2, 2, 1, 1},
+	{0, 0, 1, 1, 0, 2, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2},
+	{0, 2, 2, 2, 0, 2, 1, 1, 0, 1, 1, 1, 0, 1, 1, 1},
+	{0, 0, 1, 1, 0, 1, 1, 1, 0, 1, 1, 2, 0, 1, 1, 2},
+	{0, 0, 0, 0, 0, 1, 2, 2, 1, 1, 2, 2, 1, 1, 2, 2},
+	{0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 2, 2, 2, 2},
+	{0, 0, 0, 0, 0, 0, 2, 2, 1, 1, 2, 2, 1, 1, 2, 2},
+	{0, 0, 2, 2, 0, 0, 1, 1, 0, 1, 1, 1, 0, 1, 1, 1},
+	{0, 1, 1, 1, 0, 1, 1, 1, 0, 1, 2, 2, 2, 2, 2, 2},
+	{0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 1, 1, 2, 1, 1, 1},
+	{0, 0, 0, 1, 0, 0, 1, 1, 2, 2, 1, 1, 2, 2, 1, 1},
+	{0, 0, 0, 0, 0, 2, 1, 1, 2, 2, 1, 1, 1, 1, 1, 1},
+	{0, 0, 0, 1, 0, 2, 1, 1, 2, 2, 1, 1, 2, 2, 1, 1},
+	{0, 0, 0, 0, 0, 0, 2, 2, 0, 1, 2, 2, 1, 1, 2, 2},
+	{0, 0, 2, 2, 0, 2, 2, 2, 1, 1, 2, 2, 1, 1, 1, 1},
+	{0, 0, 0, 0, 0, 0, 2, 1, 0, 2, 2, 1, 2, 2, 2, 1},
+	{0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 1, 1, 2, 1, 1, 1},
+	{0, 0, 0, 0, 0, 2, 2, 1, 2, 2, 2, 1, 2, 2, 1, 1},
+	{0, 0, 2, 2, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1},
+	{0, 0, 0, 0, 0, 0, 0, 1, 0, 2, 1, 1, 2, 2, 1, 1},
+}
+
+// bc7FixupIndex3a/bc7FixupIndex3b give, for each three-subset partition,
+// the first texel index belonging to subset 1 and subset 2
+// respectively; their index bits are implicit and taken as 0.
+var bc7FixupIndex3a = [64]uint8{
+	2, 6, 6, 4, 5, 6, 8, 3, 4, 8, 2, 9, 7, 1, 3, 2, 5, 3, 5, 5, 6, 9, 6, 6, 2, 2, 9, 7, 9, 8, 8, 2, 2, 10, 2, 3, 3, 3, 2, 2, 8, 7, 9, 1, 2, 2, 6, 2, 5, 4, 8, 6, 1, 6, 3, 6, 3, 9, 8, 7, 6, 7, 6, 7,
+}
+
+var bc7FixupIndex3b = [64]uint8{
+	7, 2, 4, 1, 10, 7, 2, 8, 2, 5, 4, 2, 6, 8, 13, 1, 8, 5, 2, 2, 1, 8, 2, 3, 4, 4, 4, 8, 2, 2, 5, 4, 4, 3, 11, 2, 5, 13, 1, 9, 2, 2, 2, 7, 8, 5, 1, 11, 6, 12, 6, 2, 10, 8, 8, 5, 5, 6, 2, 6, 8, 5, 2, 9,
+}
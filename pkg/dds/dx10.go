@@ -0,0 +1,156 @@
+/*
+Copyright 2017 Luke Granger-Brown
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+)
+
+// fourCCDX10 marks a DDS_HEADER followed by a DDS_HEADER_DXT10 block.
+const fourCCDX10 = 0x30315844 // "DX10"
+
+// DXGIFormat mirrors the subset of DXGI_FORMAT values this package knows
+// how to decode.
+type DXGIFormat uint32
+
+const (
+	DXGIFormatUnknown      DXGIFormat = 0
+	DXGIFormatBC4Unorm     DXGIFormat = 80
+	DXGIFormatBC4Snorm     DXGIFormat = 81
+	DXGIFormatBC5Unorm     DXGIFormat = 83
+	DXGIFormatBC5Snorm     DXGIFormat = 84
+	DXGIFormatBC6HUf16     DXGIFormat = 95
+	DXGIFormatBC6HSf16     DXGIFormat = 96
+	DXGIFormatBC7Unorm     DXGIFormat = 98
+	DXGIFormatBC7UnormSRGB DXGIFormat = 99
+)
+
+// ResourceDimension mirrors D3D10_RESOURCE_DIMENSION.
+type ResourceDimension uint32
+
+const (
+	ResourceDimensionUnknown   ResourceDimension = 0
+	ResourceDimensionBuffer    ResourceDimension = 1
+	ResourceDimensionTexture1D ResourceDimension = 2
+	ResourceDimensionTexture2D ResourceDimension = 3
+	ResourceDimensionTexture3D ResourceDimension = 4
+)
+
+// miscFlag bits (DDS_HEADER_DXT10.miscFlag).
+const (
+	dx10MiscFlagTextureCube = 0x4
+)
+
+// miscFlags2 values (DDS_HEADER_DXT10.miscFlags2), identifying the
+// alpha mode in the low 3 bits.
+type AlphaMode uint32
+
+const (
+	AlphaModeUnknown       AlphaMode = 0
+	AlphaModeStraight      AlphaMode = 1
+	AlphaModePremultiplied AlphaMode = 2
+	AlphaModeOpaque        AlphaMode = 3
+	AlphaModeCustom        AlphaMode = 4
+)
+
+// headerDXT10 mirrors DDS_HEADER_DXT10, present immediately after the
+// main header when pixelFormat.fourCC is "DX10".
+type headerDXT10 struct {
+	dxgiFormat        DXGIFormat
+	resourceDimension ResourceDimension
+	miscFlag          uint32
+	arraySize         uint32
+	miscFlags2        uint32
+}
+
+// AlphaMode returns the alpha mode encoded in the low bits of MiscFlags2.
+func (h headerDXT10) AlphaMode() AlphaMode {
+	return AlphaMode(h.miscFlags2 & 0x7)
+}
+
+// IsCubemap reports whether miscFlag marks this array as a cubemap (or
+// an array of cubemaps).
+func (h headerDXT10) IsCubemap() bool {
+	return h.miscFlag&dx10MiscFlagTextureCube == dx10MiscFlagTextureCube
+}
+
+func readHeaderDXT10(r io.Reader) (headerDXT10, error) {
+	var h headerDXT10
+	if err := binary.Read(r, binary.LittleEndian, &h.dxgiFormat); err != nil {
+		return headerDXT10{}, fmt.Errorf("reading DXT10 header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.resourceDimension); err != nil {
+		return headerDXT10{}, fmt.Errorf("reading DXT10 header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.miscFlag); err != nil {
+		return headerDXT10{}, fmt.Errorf("reading DXT10 header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.arraySize); err != nil {
+		return headerDXT10{}, fmt.Errorf("reading DXT10 header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.miscFlags2); err != nil {
+		return headerDXT10{}, fmt.Errorf("reading DXT10 header: %v", err)
+	}
+	return h, nil
+}
+
+func writeHeaderDXT10(w io.Writer, h headerDXT10) error {
+	fields := []interface{}{h.dxgiFormat, h.resourceDimension, h.miscFlag, h.arraySize, h.miscFlags2}
+	for _, f := range fields {
+		if err := binary.Write(w, binary.LittleEndian, f); err != nil {
+			return fmt.Errorf("writing DXT10 header: %v", err)
+		}
+	}
+	return nil
+}
+
+// blockSizeForDXGIFormat returns the compressed block byte size for the
+// BCn formats this package decodes.
+func blockSizeForDXGIFormat(f DXGIFormat) (int, error) {
+	switch f {
+	case DXGIFormatBC4Unorm, DXGIFormatBC4Snorm:
+		return 8, nil
+	case DXGIFormatBC5Unorm, DXGIFormatBC5Snorm, DXGIFormatBC6HUf16, DXGIFormatBC6HSf16, DXGIFormatBC7Unorm, DXGIFormatBC7UnormSRGB:
+		return 16, nil
+	default:
+		return 0, fmt.Errorf("dds: unsupported DXGI format %d", f)
+	}
+}
+
+// decodeDXGIFormat decodes a block-compressed payload for one of the
+// BC4/BC5/BC6H/BC7 DXGI formats recognized by blockSizeForDXGIFormat.
+func decodeDXGIFormat(f DXGIFormat, compressed []byte, width, height int) (image.Image, error) {
+	switch f {
+	case DXGIFormatBC4Unorm:
+		return decodeBC4(compressed, width, height)
+	case DXGIFormatBC4Snorm:
+		return decodeBC4Snorm(compressed, width, height)
+	case DXGIFormatBC5Unorm:
+		return decodeBC5(compressed, width, height)
+	case DXGIFormatBC5Snorm:
+		return decodeBC5Snorm(compressed, width, height)
+	case DXGIFormatBC6HUf16, DXGIFormatBC6HSf16:
+		return decodeBC6H(compressed, width, height, f == DXGIFormatBC6HSf16)
+	case DXGIFormatBC7Unorm, DXGIFormatBC7UnormSRGB:
+		return decodeBC7(compressed, width, height)
+	default:
+		return nil, fmt.Errorf("dds: unsupported DXGI format %d", f)
+	}
+}
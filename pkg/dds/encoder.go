@@ -0,0 +1,590 @@
+/*
+Copyright 2017 Luke Granger-Brown
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"sync"
+)
+
+// Format identifies the on-disk layout EncoderOptions should use.
+type Format int
+
+const (
+	// FormatA8R8G8B8 is an uncompressed 32-bit BGRA layout.
+	FormatA8R8G8B8 Format = iota
+	// FormatX8R8G8B8 is an uncompressed 32-bit BGRX layout (alpha ignored).
+	FormatX8R8G8B8
+	// FormatR5G6B5 is an uncompressed 16-bit layout with no alpha.
+	FormatR5G6B5
+	// FormatA8 is an 8-bit alpha-only layout.
+	FormatA8
+	// FormatL8 is an 8-bit luminance-only layout.
+	FormatL8
+	// FormatL16 is a 16-bit luminance-only layout.
+	FormatL16
+	// FormatA8L8 is an 8-bit luminance + 8-bit alpha layout.
+	FormatA8L8
+	// FormatDXT1 is the block-compressed S3TC DXT1 FourCC.
+	FormatDXT1
+	// FormatDXT3 is the block-compressed S3TC DXT3 FourCC.
+	FormatDXT3
+	// FormatDXT5 is the block-compressed S3TC DXT5 FourCC.
+	FormatDXT5
+	// FormatBC4 is the block-compressed BC4/ATI1 FourCC (DetectFormat only).
+	FormatBC4
+	// FormatBC5 is the block-compressed BC5/ATI2 FourCC (DetectFormat only).
+	FormatBC5
+	// FormatBC6H is the DX10 BC6H HDR codec (DetectFormat only).
+	FormatBC6H
+	// FormatBC7 is the DX10 BC7 codec (DetectFormat only).
+	FormatBC7
+	// FormatUncompressedRGBA8 is what DetectFormat reports for every
+	// uncompressed (non-FourCC) surface; it resolves neither the bit
+	// count nor the channel order.
+	FormatUncompressedRGBA8
+	// FormatUnknown is returned by DetectFormat for a pixel format it
+	// doesn't recognize.
+	FormatUnknown
+)
+
+// EncoderOptions configures Encode and Encoder.Encode.
+type EncoderOptions struct {
+	// Format selects the on-disk pixel layout or block-compressed FourCC.
+	Format Format
+}
+
+// BufferPool is implemented by types which can manage reuse of
+// EncoderBuffers. Callers that encode many images can use a BufferPool to
+// avoid allocating a fresh scratch buffer for every call to Encode.
+//
+// It is modelled on image/png.BufferPool.
+type BufferPool interface {
+	Get() *EncoderBuffer
+	Put(*EncoderBuffer)
+}
+
+// EncoderBuffer holds the scratch memory an Encoder needs while writing a
+// single image. Reusing one across calls avoids re-allocating the
+// compressed-block or scanline buffer for every texture.
+type EncoderBuffer struct {
+	buf []byte
+}
+
+// bytes returns b's backing slice resized to n bytes, reusing the
+// existing allocation when it is large enough.
+func (b *EncoderBuffer) bytes(n int) []byte {
+	if cap(b.buf) < n {
+		b.buf = make([]byte, n)
+	}
+	return b.buf[:n]
+}
+
+// Encoder writes DDS images, analogous to png.Encoder.
+type Encoder struct {
+	// BufferPool, if non-nil, is used to obtain and return the
+	// EncoderBuffer used for each Encode call.
+	BufferPool BufferPool
+}
+
+// Encode writes m to w using opts, allocating its own scratch buffer.
+func Encode(w io.Writer, m image.Image, opts *EncoderOptions) error {
+	var e Encoder
+	return e.Encode(w, m, opts)
+}
+
+// Encode writes m to w using opts. If e.BufferPool is set, the
+// EncoderBuffer it provides is reused for the scratch space needed to
+// build the pixel or block-compressed payload.
+func (e *Encoder) Encode(w io.Writer, m image.Image, opts *EncoderOptions) error {
+	if opts == nil {
+		opts = &EncoderOptions{Format: FormatA8R8G8B8}
+	}
+
+	var eb *EncoderBuffer
+	if e.BufferPool != nil {
+		eb = e.BufferPool.Get()
+		defer e.BufferPool.Put(eb)
+	} else {
+		eb = &EncoderBuffer{}
+	}
+
+	bounds := m.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("dds: cannot encode empty image")
+	}
+
+	switch opts.Format {
+	case FormatA8R8G8B8, FormatX8R8G8B8, FormatR5G6B5, FormatA8, FormatL8, FormatL16, FormatA8L8:
+		return e.encodeUncompressed(w, m, opts.Format, eb)
+	case FormatDXT1, FormatDXT3, FormatDXT5:
+		return e.encodeBlockCompressed(w, m, opts.Format, eb)
+	default:
+		return fmt.Errorf("dds: unsupported encoder format %v", opts.Format)
+	}
+}
+
+// uncompressedLayout describes the bit layout written for each
+// uncompressed Format.
+type uncompressedLayout struct {
+	flags                      uint32
+	bitCount                   uint32
+	rMask, gMask, bMask, aMask uint32
+}
+
+func layoutFor(f Format) (uncompressedLayout, error) {
+	switch f {
+	case FormatA8R8G8B8:
+		return uncompressedLayout{pfAlphaPixels | pfRGB, 32, 0x00ff0000, 0x0000ff00, 0x000000ff, 0xff000000}, nil
+	case FormatX8R8G8B8:
+		return uncompressedLayout{pfRGB, 32, 0x00ff0000, 0x0000ff00, 0x000000ff, 0}, nil
+	case FormatR5G6B5:
+		return uncompressedLayout{pfRGB, 16, 0xf800, 0x07e0, 0x001f, 0}, nil
+	case FormatA8:
+		return uncompressedLayout{pfAlpha, 8, 0, 0, 0, 0xff}, nil
+	case FormatL8:
+		return uncompressedLayout{pfLuminance, 8, 0xff, 0, 0, 0}, nil
+	case FormatL16:
+		return uncompressedLayout{pfLuminance, 16, 0xffff, 0, 0, 0}, nil
+	case FormatA8L8:
+		return uncompressedLayout{pfLuminance | pfAlphaPixels, 16, 0x00ff, 0, 0, 0xff00}, nil
+	default:
+		return uncompressedLayout{}, fmt.Errorf("dds: %v is not an uncompressed format", f)
+	}
+}
+
+func (e *Encoder) encodeUncompressed(w io.Writer, m image.Image, f Format, eb *EncoderBuffer) error {
+	layout, err := layoutFor(f)
+	if err != nil {
+		return err
+	}
+
+	bounds := m.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	stride := layout.bitCount / 8
+	pitch := uint32(width) * stride
+
+	h := header{
+		size:              124,
+		flags:             headerFlagsCaps | headerFlagsHeight | headerFlagsWidth | headerFlagsPixelFormat | headerFlagsPitch,
+		height:            uint32(height),
+		width:             uint32(width),
+		pitchOrLinearSize: pitch,
+		pixelFormat: pixelFormat{
+			size:        32,
+			flags:       layout.flags,
+			rgbBitCount: layout.bitCount,
+			rBitMask:    layout.rMask,
+			gBitMask:    layout.gMask,
+			bBitMask:    layout.bMask,
+			aBitMask:    layout.aMask,
+		},
+		caps: capsTexture,
+	}
+	if err := writeHeader(w, h); err != nil {
+		return err
+	}
+
+	rBit := lowestSetBit(layout.rMask)
+	gBit := lowestSetBit(layout.gMask)
+	bBit := lowestSetBit(layout.bMask)
+	aBit := lowestSetBit(layout.aMask)
+	scale := func(c uint8, mask uint32) uint32 {
+		width := 0
+		for m := mask; m != 0; m &= m - 1 {
+			width++
+		}
+		if width > 8 {
+			return uint32(c) * 0x101
+		}
+		return uint32(c) >> uint(8-width)
+	}
+
+	row := eb.bytes(int(pitch))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, a := colorToChannels(m.At(bounds.Min.X+x, bounds.Min.Y+y))
+			var v uint32
+			v |= (scale(r, layout.rMask) << rBit) & layout.rMask
+			v |= (scale(g, layout.gMask) << gBit) & layout.gMask
+			v |= (scale(b, layout.bMask) << bBit) & layout.bMask
+			v |= (scale(a, layout.aMask) << aBit) & layout.aMask
+
+			off := x * int(stride)
+			for i := uint32(0); i < stride; i++ {
+				row[off+int(i)] = byte(v >> (8 * i))
+			}
+		}
+		if _, err := w.Write(row); err != nil {
+			return fmt.Errorf("writing scanline: %v", err)
+		}
+	}
+	return nil
+}
+
+// colorToChannels converts c to 8-bit-per-channel RGBA, matching the
+// precision every uncompressed EncoderOptions layout downsamples from.
+func colorToChannels(c color.Color) (r, g, b, a uint8) {
+	cr, cg, cb, ca := c.RGBA()
+	return uint8(cr >> 8), uint8(cg >> 8), uint8(cb >> 8), uint8(ca >> 8)
+}
+
+func (e *Encoder) encodeBlockCompressed(w io.Writer, m image.Image, f Format, eb *EncoderBuffer) error {
+	bounds := m.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	var fourCC uint32
+	var blockSize int
+	switch f {
+	case FormatDXT1:
+		fourCC, blockSize = fourCCDXT1, 8
+	case FormatDXT3:
+		fourCC, blockSize = fourCCDXT3, 16
+	case FormatDXT5:
+		fourCC, blockSize = fourCCDXT5, 16
+	}
+
+	blockWidth := (width + 3) / 4
+	blockHeight := (height + 3) / 4
+	linearSize := uint32(blockWidth * blockHeight * blockSize)
+
+	h := header{
+		size:              124,
+		flags:             headerFlagsCaps | headerFlagsHeight | headerFlagsWidth | headerFlagsPixelFormat | headerFlagsLinearSize,
+		height:            uint32(height),
+		width:             uint32(width),
+		pitchOrLinearSize: linearSize,
+		pixelFormat: pixelFormat{
+			size:   32,
+			flags:  pfFourCC,
+			fourCC: fourCC,
+		},
+		caps: capsTexture,
+	}
+	if err := writeHeader(w, h); err != nil {
+		return err
+	}
+
+	buf := eb.bytes(blockWidth * blockHeight * blockSize)
+	var block [16]color.RGBA
+	for by := 0; by < blockHeight; by++ {
+		for bx := 0; bx < blockWidth; bx++ {
+			readBlock(m, bounds, bx*4, by*4, &block)
+
+			out := buf[(by*blockWidth+bx)*blockSize:]
+			switch f {
+			case FormatDXT1:
+				encodeBlockDXT1(&block, out[:8])
+			case FormatDXT3:
+				encodeBlockDXT3(&block, out[:16])
+			case FormatDXT5:
+				encodeBlockDXT5(&block, out[:16])
+			}
+		}
+	}
+
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("writing compressed data: %v", err)
+	}
+	return nil
+}
+
+// readBlock fills block with the 4x4 texel neighbourhood of m starting at
+// (x,y) relative to bounds, clamping to the last valid row/column for
+// partial edge blocks.
+func readBlock(m image.Image, bounds image.Rectangle, x, y int, block *[16]color.RGBA) {
+	w, h := bounds.Dx(), bounds.Dy()
+	for j := 0; j < 4; j++ {
+		py := y + j
+		if py >= h {
+			py = h - 1
+		}
+		for i := 0; i < 4; i++ {
+			px := x + i
+			if px >= w {
+				px = w - 1
+			}
+			r, g, b, a := colorToChannels(m.At(bounds.Min.X+px, bounds.Min.Y+py))
+			block[j*4+i] = color.RGBA{r, g, b, a}
+		}
+	}
+}
+
+// principalAxisEndpoints finds the two extreme colors of block along its
+// principal axis via four power-iteration steps; this is the
+// "range fit" quantizer used by encodeBlockDXT1/3/5.
+func principalAxisEndpoints(block *[16]color.RGBA) (color.RGBA, color.RGBA) {
+	var mean [3]float64
+	for _, c := range block {
+		mean[0] += float64(c.R)
+		mean[1] += float64(c.G)
+		mean[2] += float64(c.B)
+	}
+	mean[0] /= 16
+	mean[1] /= 16
+	mean[2] /= 16
+
+	var cov [3][3]float64
+	for _, c := range block {
+		d := [3]float64{float64(c.R) - mean[0], float64(c.G) - mean[1], float64(c.B) - mean[2]}
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				cov[i][j] += d[i] * d[j]
+			}
+		}
+	}
+
+	axis := [3]float64{1, 1, 1}
+	for iter := 0; iter < 4; iter++ {
+		var next [3]float64
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				next[i] += cov[i][j] * axis[j]
+			}
+		}
+		norm := next[0]*next[0] + next[1]*next[1] + next[2]*next[2]
+		if norm < 1e-12 {
+			// Degenerate (flat) block: fall back to RGB565-space min/max.
+			return minMaxRGB565(block)
+		}
+		axis = next
+	}
+
+	minDot, maxDot := 1e18, -1e18
+	var minC, maxC color.RGBA
+	for _, c := range block {
+		d := [3]float64{float64(c.R) - mean[0], float64(c.G) - mean[1], float64(c.B) - mean[2]}
+		dot := d[0]*axis[0] + d[1]*axis[1] + d[2]*axis[2]
+		if dot < minDot {
+			minDot = dot
+			minC = c
+		}
+		if dot > maxDot {
+			maxDot = dot
+			maxC = c
+		}
+	}
+	return minC, maxC
+}
+
+// minMaxRGB565 is the cheap fallback endpoint picker: the per-channel
+// min and max of the block, independently of the others.
+func minMaxRGB565(block *[16]color.RGBA) (color.RGBA, color.RGBA) {
+	min := color.RGBA{255, 255, 255, 255}
+	max := color.RGBA{0, 0, 0, 0}
+	for _, c := range block {
+		if c.R < min.R {
+			min.R = c.R
+		}
+		if c.G < min.G {
+			min.G = c.G
+		}
+		if c.B < min.B {
+			min.B = c.B
+		}
+		if c.R > max.R {
+			max.R = c.R
+		}
+		if c.G > max.G {
+			max.G = c.G
+		}
+		if c.B > max.B {
+			max.B = c.B
+		}
+	}
+	return min, max
+}
+
+func rgbaToRGB565(c color.RGBA) uint16 {
+	r := uint16(c.R>>3) & 0x1f
+	g := uint16(c.G>>2) & 0x3f
+	b := uint16(c.B>>3) & 0x1f
+	return r<<11 | g<<5 | b
+}
+
+// orderedRGB565Endpoints packs minC/maxC to RGB565 and forces c0 > c1 so
+// the block decodes in the four-color interpolation mode: because R/G/B
+// have unequal bit widths in RGB565, the packed 16-bit value doesn't
+// necessarily preserve the original min/max ordering, and a decoder
+// treats c0 <= c1 as the three-color-plus-transparent-black mode.
+func orderedRGB565Endpoints(minC, maxC color.RGBA) (c0, c1 uint16) {
+	c0, c1 = rgbaToRGB565(maxC), rgbaToRGB565(minC)
+	if c0 < c1 {
+		c0, c1 = c1, c0
+	}
+	if c0 == c1 {
+		if c0 == 0xffff {
+			c1--
+		} else {
+			c0++
+		}
+	}
+	return c0, c1
+}
+
+// nearestIndex returns the index (0-3) of the palette entry closest to c.
+func nearestIndex(c color.RGBA, palette [4]color.RGBA) uint32 {
+	best := uint32(0)
+	bestDist := -1
+	for i, p := range palette {
+		dr := int(c.R) - int(p.R)
+		dg := int(c.G) - int(p.G)
+		db := int(c.B) - int(p.B)
+		dist := dr*dr + dg*dg + db*db
+		if bestDist < 0 || dist < bestDist {
+			bestDist = dist
+			best = uint32(i)
+		}
+	}
+	return best
+}
+
+// colorIndices quantizes block against c0/c1 (and their two interpolated
+// midpoints) and packs the 16 2-bit indices into the DXT colorData word.
+func colorIndices(block *[16]color.RGBA, c0, c1 uint16, palette [4]color.RGBA) uint32 {
+	var colorData uint32
+	for i, texel := range block {
+		idx := nearestIndex(texel, palette)
+		colorData |= idx << uint(i*2)
+	}
+	return colorData
+}
+
+func encodeBlockDXT1(block *[16]color.RGBA, out []byte) {
+	minC, maxC := principalAxisEndpoints(block)
+	c0, c1 := orderedRGB565Endpoints(minC, maxC)
+
+	palette := [4]color.RGBA{
+		rgb565ToRGBAColor(c0),
+		rgb565ToRGBAColor(c1),
+	}
+	palette[2] = interpolateColors(palette[0], palette[1], 2, 1)
+	palette[3] = interpolateColors(palette[0], palette[1], 1, 2)
+
+	colorData := colorIndices(block, c0, c1, palette)
+
+	binary.LittleEndian.PutUint16(out[0:2], c0)
+	binary.LittleEndian.PutUint16(out[2:4], c1)
+	binary.LittleEndian.PutUint32(out[4:8], colorData)
+}
+
+func encodeBlockDXT3(block *[16]color.RGBA, out []byte) {
+	var alphaData uint64
+	for i, texel := range block {
+		a4 := uint64(texel.A) >> 4
+		alphaData |= a4 << uint(i*4)
+	}
+
+	minC, maxC := principalAxisEndpoints(block)
+	c0, c1 := orderedRGB565Endpoints(minC, maxC)
+	palette := [4]color.RGBA{
+		rgb565ToRGBAColor(c0),
+		rgb565ToRGBAColor(c1),
+	}
+	palette[2] = interpolateColors(palette[0], palette[1], 2, 1)
+	palette[3] = interpolateColors(palette[0], palette[1], 1, 2)
+
+	colorData := colorIndices(block, c0, c1, palette)
+
+	binary.LittleEndian.PutUint64(out[0:8], alphaData)
+	binary.LittleEndian.PutUint16(out[8:10], c0)
+	binary.LittleEndian.PutUint16(out[10:12], c1)
+	binary.LittleEndian.PutUint32(out[12:16], colorData)
+}
+
+func encodeBlockDXT5(block *[16]color.RGBA, out []byte) {
+	alpha0, alpha1 := uint8(0), uint8(255)
+	for _, texel := range block {
+		if texel.A > alpha0 {
+			alpha0 = texel.A
+		}
+		if texel.A < alpha1 {
+			alpha1 = texel.A
+		}
+	}
+
+	alphaPalette := [8]uint8{
+		alpha0, alpha1,
+		uint8((6*int(alpha0) + 1*int(alpha1)) / 7),
+		uint8((5*int(alpha0) + 2*int(alpha1)) / 7),
+		uint8((4*int(alpha0) + 3*int(alpha1)) / 7),
+		uint8((3*int(alpha0) + 4*int(alpha1)) / 7),
+		uint8((2*int(alpha0) + 5*int(alpha1)) / 7),
+		uint8((1*int(alpha0) + 6*int(alpha1)) / 7),
+	}
+
+	var alphaData uint64
+	for i, texel := range block {
+		best, bestDist := 0, -1
+		for j, a := range alphaPalette {
+			d := int(texel.A) - int(a)
+			if d < 0 {
+				d = -d
+			}
+			if bestDist < 0 || d < bestDist {
+				bestDist = d
+				best = j
+			}
+		}
+		alphaData |= uint64(best) << uint(i*3)
+	}
+
+	minC, maxC := principalAxisEndpoints(block)
+	c0, c1 := orderedRGB565Endpoints(minC, maxC)
+	palette := [4]color.RGBA{
+		rgb565ToRGBAColor(c0),
+		rgb565ToRGBAColor(c1),
+	}
+	palette[2] = interpolateColors(palette[0], palette[1], 2, 1)
+	palette[3] = interpolateColors(palette[0], palette[1], 1, 2)
+
+	colorData := colorIndices(block, c0, c1, palette)
+
+	alphaHeader := alphaData<<16 | uint64(alpha1)<<8 | uint64(alpha0)
+	binary.LittleEndian.PutUint64(out[0:8], alphaHeader)
+	binary.LittleEndian.PutUint16(out[8:10], c0)
+	binary.LittleEndian.PutUint16(out[10:12], c1)
+	binary.LittleEndian.PutUint32(out[12:16], colorData)
+}
+
+// syncPool-backed BufferPool is the pool implementation returned by
+// NewBufferPool, matching the usage pattern of image/png's pool.
+type syncBufferPool struct {
+	pool sync.Pool
+}
+
+// NewBufferPool returns a BufferPool backed by a sync.Pool, suitable for
+// callers that re-encode many textures and want to avoid the per-call
+// scratch allocation.
+func NewBufferPool() BufferPool {
+	return &syncBufferPool{
+		pool: sync.Pool{New: func() interface{} { return &EncoderBuffer{} }},
+	}
+}
+
+func (p *syncBufferPool) Get() *EncoderBuffer {
+	return p.pool.Get().(*EncoderBuffer)
+}
+
+func (p *syncBufferPool) Put(b *EncoderBuffer) {
+	p.pool.Put(b)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2017 Luke Granger-Brown
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"fmt"
+	"io"
+)
+
+// DetectFormat reads just the DDS header (and, for DX10 files, the
+// DDS_HEADER_DXT10 extension) from r and reports which Format the
+// surface data uses, without allocating anything for the surface
+// itself. This lets a caller reject or size-check a file - e.g. a 4K
+// cubemap it doesn't want to decode - before committing to the cost of
+// Decode or DecodeAll.
+//
+// Like DecodeConfig, DetectFormat consumes header bytes from r; decode
+// the same stream afterwards only if you reopened or seeked it back to
+// the start.
+func DetectFormat(r io.Reader) (Format, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return FormatUnknown, err
+	}
+
+	if h.pixelFormat.flags&pfFourCC != pfFourCC {
+		return FormatUncompressedRGBA8, nil
+	}
+
+	switch h.pixelFormat.fourCC {
+	case fourCCDXT1:
+		return FormatDXT1, nil
+	case fourCCDXT2, fourCCDXT3:
+		return FormatDXT3, nil
+	case fourCCDXT4, fourCCDXT5:
+		return FormatDXT5, nil
+	case 0x31495441: // "ATI1"
+		return FormatBC4, nil
+	case 0x32495441: // "ATI2"
+		return FormatBC5, nil
+	case fourCCDX10:
+		dx10, err := readHeaderDXT10(r)
+		if err != nil {
+			return FormatUnknown, err
+		}
+		switch dx10.dxgiFormat {
+		case DXGIFormatBC4Unorm, DXGIFormatBC4Snorm:
+			return FormatBC4, nil
+		case DXGIFormatBC5Unorm, DXGIFormatBC5Snorm:
+			return FormatBC5, nil
+		case DXGIFormatBC6HUf16, DXGIFormatBC6HSf16:
+			return FormatBC6H, nil
+		case DXGIFormatBC7Unorm, DXGIFormatBC7UnormSRGB:
+			return FormatBC7, nil
+		default:
+			return FormatUnknown, fmt.Errorf("dds: unrecognized DXGI format %d", dx10.dxgiFormat)
+		}
+	default:
+		return FormatUnknown, fmt.Errorf("dds: unrecognized FourCC %#x", h.pixelFormat.fourCC)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2017 Luke Granger-Brown
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// HDRColor is a floating-point RGB color with no alpha, the native
+// output of the BC6H decoder.
+type HDRColor struct {
+	R, G, B float32
+}
+
+// RGBA implements color.Color by clamping each channel into [0,1] and
+// scaling to the full uint16 range, matching color.RGBA64Model's
+// domain.
+func (c HDRColor) RGBA() (r, g, b, a uint32) {
+	scale := func(v float32) uint32 {
+		if v <= 0 {
+			return 0
+		}
+		if v >= 1 {
+			return 0xffff
+		}
+		return uint32(v * 0xffff)
+	}
+	return scale(c.R), scale(c.G), scale(c.B), 0xffff
+}
+
+// hdrColorModel implements color.Model for HDRImage by converting
+// through color.RGBA64, the closest standard-library representation of
+// an HDRColor's dynamic range.
+var hdrColorModel = color.ModelFunc(func(c color.Color) color.Color {
+	if _, ok := c.(HDRColor); ok {
+		return c
+	}
+	r, g, b, _ := color.RGBA64Model.Convert(c).RGBA()
+	return HDRColor{
+		R: float32(r) / 0xffff,
+		G: float32(g) / 0xffff,
+		B: float32(b) / 0xffff,
+	}
+})
+
+// HDRImage is an image.Image of floating-point RGB texels, used to
+// represent decoded BC6H surfaces without clamping their extended
+// dynamic range down to 8 bits per channel. The raw per-texel floats
+// are available via At and, for bulk access, Float32At.
+type HDRImage struct {
+	Pix    []float32 // R,G,B triples in row-major order
+	Stride int       // elements (not bytes) between vertically adjacent texels
+	Rect   image.Rectangle
+}
+
+// NewHDRImage returns a new HDRImage covering r.
+func NewHDRImage(r image.Rectangle) *HDRImage {
+	return &HDRImage{
+		Pix:    make([]float32, 3*r.Dx()*r.Dy()),
+		Stride: 3 * r.Dx(),
+		Rect:   r,
+	}
+}
+
+func (h *HDRImage) ColorModel() color.Model { return hdrColorModel }
+
+func (h *HDRImage) Bounds() image.Rectangle { return h.Rect }
+
+func (h *HDRImage) At(x, y int) color.Color {
+	return h.Float32At(x, y)
+}
+
+// Float32At returns the raw, unclamped floating-point texel at (x, y).
+func (h *HDRImage) Float32At(x, y int) HDRColor {
+	if !(image.Point{x, y}.In(h.Rect)) {
+		return HDRColor{}
+	}
+	i := h.PixOffset(x, y)
+	return HDRColor{R: h.Pix[i], G: h.Pix[i+1], B: h.Pix[i+2]}
+}
+
+// SetFloat32 stores the raw floating-point texel at (x, y).
+func (h *HDRImage) SetFloat32(x, y int, c HDRColor) {
+	if !(image.Point{x, y}.In(h.Rect)) {
+		return
+	}
+	i := h.PixOffset(x, y)
+	h.Pix[i], h.Pix[i+1], h.Pix[i+2] = c.R, c.G, c.B
+}
+
+// PixOffset returns the index of the first float32 element of the
+// texel at (x, y).
+func (h *HDRImage) PixOffset(x, y int) int {
+	return (y-h.Rect.Min.Y)*h.Stride + (x-h.Rect.Min.X)*3
+}
+
+// float16ToFloat32 converts an IEEE 754 half-precision bit pattern to a
+// float32.
+func float16ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := (h >> 10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch exp {
+	case 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		// Subnormal half -> normalize into a float32.
+		e := 0
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		mant &= 0x3ff
+		exp32 := uint32(127-15+e+1) << 23
+		return math.Float32frombits(sign | exp32 | (mant << 13))
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | (mant << 13))
+	default:
+		exp32 := (uint32(exp) - 15 + 127) << 23
+		return math.Float32frombits(sign | exp32 | (mant << 13))
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2017 Luke Granger-Brown
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// magic is the four bytes every DDS file starts with.
+const magic = "DDS "
+
+// pixelFormat flags (DDS_PIXELFORMAT.dwFlags).
+const (
+	pfAlphaPixels = 0x1
+	pfAlpha       = 0x2
+	pfFourCC      = 0x4
+	pfRGB         = 0x40
+	pfYUV         = 0x200
+	pfLuminance   = 0x20000
+)
+
+// FourCC values recognized in pixelFormat.fourCC.
+const (
+	fourCCDXT1 = 0x31545844 // "DXT1"
+	fourCCDXT2 = 0x32545844 // "DXT2"
+	fourCCDXT3 = 0x33545844 // "DXT3"
+	fourCCDXT4 = 0x34545844 // "DXT4"
+	fourCCDXT5 = 0x35545844 // "DXT5"
+)
+
+// header flags (DDS_HEADER.dwFlags).
+const (
+	headerFlagsCaps        = 0x1
+	headerFlagsHeight      = 0x2
+	headerFlagsWidth       = 0x4
+	headerFlagsPitch       = 0x8
+	headerFlagsPixelFormat = 0x1000
+	headerFlagsMipMapCount = 0x20000
+	headerFlagsLinearSize  = 0x80000
+	headerFlagsDepth       = 0x800000
+)
+
+// caps flags (DDS_HEADER.dwCaps).
+const (
+	capsComplex = 0x8
+	capsMipMap  = 0x400000
+	capsTexture = 0x1000
+)
+
+// caps2 flags (DDS_HEADER.dwCaps2).
+const (
+	caps2Cubemap          = 0x200
+	caps2CubemapPositiveX = 0x400
+	caps2CubemapNegativeX = 0x800
+	caps2CubemapPositiveY = 0x1000
+	caps2CubemapNegativeY = 0x2000
+	caps2CubemapPositiveZ = 0x4000
+	caps2CubemapNegativeZ = 0x8000
+	caps2CubemapAllFaces  = caps2CubemapPositiveX | caps2CubemapNegativeX |
+		caps2CubemapPositiveY | caps2CubemapNegativeY |
+		caps2CubemapPositiveZ | caps2CubemapNegativeZ
+	caps2Volume = 0x200000
+)
+
+// pixelFormat mirrors DDS_PIXELFORMAT.
+type pixelFormat struct {
+	size        uint32
+	flags       uint32
+	fourCC      uint32
+	rgbBitCount uint32
+	rBitMask    uint32
+	gBitMask    uint32
+	bBitMask    uint32
+	aBitMask    uint32
+}
+
+// header mirrors DDS_HEADER.
+type header struct {
+	size              uint32
+	flags             uint32
+	height            uint32
+	width             uint32
+	pitchOrLinearSize uint32
+	depth             uint32
+	mipMapCount       uint32
+	reserved1         [11]uint32
+	pixelFormat       pixelFormat
+	caps              uint32
+	caps2             uint32
+	caps3             uint32
+	caps4             uint32
+	reserved2         uint32
+}
+
+// readHeader reads the magic number and the fixed-size DDS_HEADER from r.
+func readHeader(r io.Reader) (header, error) {
+	var m [4]byte
+	if _, err := io.ReadFull(r, m[:]); err != nil {
+		return header{}, fmt.Errorf("reading magic: %v", err)
+	}
+	if string(m[:]) != magic {
+		return header{}, fmt.Errorf("not a DDS file: bad magic %q", m[:])
+	}
+
+	var h header
+	if err := binary.Read(r, binary.LittleEndian, &h.size); err != nil {
+		return header{}, fmt.Errorf("reading header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.flags); err != nil {
+		return header{}, fmt.Errorf("reading header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.height); err != nil {
+		return header{}, fmt.Errorf("reading header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.width); err != nil {
+		return header{}, fmt.Errorf("reading header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.pitchOrLinearSize); err != nil {
+		return header{}, fmt.Errorf("reading header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.depth); err != nil {
+		return header{}, fmt.Errorf("reading header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.mipMapCount); err != nil {
+		return header{}, fmt.Errorf("reading header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.reserved1); err != nil {
+		return header{}, fmt.Errorf("reading header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.pixelFormat.size); err != nil {
+		return header{}, fmt.Errorf("reading pixel format: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.pixelFormat.flags); err != nil {
+		return header{}, fmt.Errorf("reading pixel format: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.pixelFormat.fourCC); err != nil {
+		return header{}, fmt.Errorf("reading pixel format: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.pixelFormat.rgbBitCount); err != nil {
+		return header{}, fmt.Errorf("reading pixel format: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.pixelFormat.rBitMask); err != nil {
+		return header{}, fmt.Errorf("reading pixel format: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.pixelFormat.gBitMask); err != nil {
+		return header{}, fmt.Errorf("reading pixel format: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.pixelFormat.bBitMask); err != nil {
+		return header{}, fmt.Errorf("reading pixel format: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.pixelFormat.aBitMask); err != nil {
+		return header{}, fmt.Errorf("reading pixel format: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.caps); err != nil {
+		return header{}, fmt.Errorf("reading header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.caps2); err != nil {
+		return header{}, fmt.Errorf("reading header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.caps3); err != nil {
+		return header{}, fmt.Errorf("reading header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.caps4); err != nil {
+		return header{}, fmt.Errorf("reading header: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.reserved2); err != nil {
+		return header{}, fmt.Errorf("reading header: %v", err)
+	}
+
+	return h, nil
+}
+
+// writeHeader writes the magic number and the fixed-size DDS_HEADER to w.
+func writeHeader(w io.Writer, h header) error {
+	if _, err := io.WriteString(w, magic); err != nil {
+		return fmt.Errorf("writing magic: %v", err)
+	}
+	fields := []interface{}{
+		h.size,
+		h.flags,
+		h.height,
+		h.width,
+		h.pitchOrLinearSize,
+		h.depth,
+		h.mipMapCount,
+		h.reserved1,
+		h.pixelFormat.size,
+		h.pixelFormat.flags,
+		h.pixelFormat.fourCC,
+		h.pixelFormat.rgbBitCount,
+		h.pixelFormat.rBitMask,
+		h.pixelFormat.gBitMask,
+		h.pixelFormat.bBitMask,
+		h.pixelFormat.aBitMask,
+		h.caps,
+		h.caps2,
+		h.caps3,
+		h.caps4,
+		h.reserved2,
+	}
+	for _, f := range fields {
+		if err := binary.Write(w, binary.LittleEndian, f); err != nil {
+			return fmt.Errorf("writing header: %v", err)
+		}
+	}
+	return nil
+}
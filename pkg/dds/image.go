@@ -29,6 +29,8 @@ import (
 	"image"
 	"image/color"
 	"io"
+	"runtime"
+	"sync"
 )
 
 func init() {
@@ -101,88 +103,37 @@ func (i *img) At(x, y int) color.Color {
 	return color.NRGBA{r, g, b, a}
 }
 
+// Decode reads just the top-level surface of a DDS file, using the same
+// format dispatch as DecodeAll(r) followed by Surface(0, 0, 0), but
+// without allocating the rest of the mip chain/cubemap faces/array
+// slices. Callers that need those should use DecodeAll instead. Unlike
+// DecodeAll, Decode rejects an uncompressed surface whose pixel-format
+// flags are anything other than exactly RGB plus alpha pixels.
 func Decode(r io.Reader) (image.Image, error) {
+	return decode(r, 1)
+}
+
+// decode implements Decode and Decoder.Decode, splitting block-compressed
+// surfaces across parallelism goroutines. It shares its FourCC/DXT10
+// dispatch with DecodeAll via decodeSurface, so the two can't drift
+// apart again.
+func decode(r io.Reader, parallelism int) (image.Image, error) {
 	h, err := readHeader(r)
 	if err != nil {
 		return nil, err
 	}
 
-	if h.pixelFormat.flags&pfFourCC == pfFourCC {
-		fourCC := uint32(861165636)
-		bytes := make([]byte, 4)
-		binary.LittleEndian.PutUint32(bytes, fourCC)
-
-		switch string(bytes) {
-		case "DXT1":
-			// ファイルから圧縮データを読み込む
-			compressedData := make([]byte, h.width*h.height)
-			if _, err := io.ReadFull(r, compressedData); err != nil {
-				return nil, fmt.Errorf("reading compressed image data: %v", err)
-			}
-
-			// DXT1デコード処理
-			img, err := decodeDXT1(compressedData, int(h.width), int(h.height))
-			if err != nil {
-				return nil, fmt.Errorf("decoding DXT1: %v", err)
-			}
-
-			return img, nil
-		case "DXT2", "DXT3":
-			// ファイルから圧縮データを読み込む
-			compressedData := make([]byte, h.width*h.height)
-			if _, err := io.ReadFull(r, compressedData); err != nil {
-				return nil, fmt.Errorf("reading compressed image data: %v", err)
-			}
-
-			// DXT3デコード処理
-			img, err := decodeDXT3(compressedData, int(h.width), int(h.height))
-			if err != nil {
-				return nil, fmt.Errorf("decoding DXT3: %v", err)
-			}
-
-			return img, nil
-		case "DXT4", "DXT5":
-			// ファイルから圧縮データを読み込む
-			compressedData := make([]byte, h.width*h.height)
-			if _, err := io.ReadFull(r, compressedData); err != nil {
-				return nil, fmt.Errorf("reading compressed image data: %v", err)
-			}
-
-			// DXT5デコード処理
-			img, err := decodeDXT5(compressedData, int(h.width), int(h.height))
-			if err != nil {
-				return nil, fmt.Errorf("decoding DXT5: %v", err)
-			}
-
-			return img, nil
-		default:
-			return nil, fmt.Errorf("unsupported FourCC %q", string(bytes))
-		}
-	}
-
-	if h.pixelFormat.flags != pfAlphaPixels|pfRGB {
+	if h.pixelFormat.flags&pfFourCC != pfFourCC && h.pixelFormat.flags != pfAlphaPixels|pfRGB {
 		return nil, fmt.Errorf("unsupported pixel format %x", h.pixelFormat.flags)
 	}
 
-	pitch := (h.width*h.pixelFormat.rgbBitCount + 7) / 8
-	buf := make([]byte, pitch*h.height)
-	if _, err := io.ReadFull(r, buf); err != nil {
-		return nil, fmt.Errorf("reading image: %v", err)
+	fmtInfo, _, err := readSurfaceFormat(r, h)
+	if err != nil {
+		return nil, err
 	}
-	stride := h.pixelFormat.rgbBitCount / 8
-
-	return &img{
-		h:   h,
-		buf: buf,
-
-		pitch:  int(pitch),
-		stride: int(stride),
 
-		rBit: lowestSetBit(h.pixelFormat.rBitMask),
-		gBit: lowestSetBit(h.pixelFormat.gBitMask),
-		bBit: lowestSetBit(h.pixelFormat.bBitMask),
-		aBit: lowestSetBit(h.pixelFormat.aBitMask),
-	}, nil
+	img, _, err := decodeSurface(r, fmtInfo, int(h.width), int(h.height), parallelism)
+	return img, err
 }
 
 func rgb565ToRGBAColor(c uint16) color.RGBA {
@@ -192,43 +143,104 @@ func rgb565ToRGBAColor(c uint16) color.RGBA {
 	return color.RGBA{r, g, b, 255}
 }
 
+// interpolateColors blends c0 and c1 using the exact integer formulas the
+// S3TC spec mandates: a 2:1 or 1:2 blend is (2*c0+c1+1)/3 (rounded), while
+// an even 1:1 blend is (c0+c1)/2 (not rounded).
 func interpolateColors(c0, c1 color.RGBA, w0, w1 int) color.RGBA {
-	r := (int(c0.R)*w0 + int(c1.R)*w1) / (w0 + w1)
-	g := (int(c0.G)*w0 + int(c1.G)*w1) / (w0 + w1)
-	b := (int(c0.B)*w0 + int(c1.B)*w1) / (w0 + w1)
+	if w0+w1 == 3 {
+		r := (int(c0.R)*w0 + int(c1.R)*w1 + 1) / 3
+		g := (int(c0.G)*w0 + int(c1.G)*w1 + 1) / 3
+		b := (int(c0.B)*w0 + int(c1.B)*w1 + 1) / 3
+		return color.RGBA{uint8(r), uint8(g), uint8(b), 255}
+	}
+	r := (int(c0.R) + int(c1.R)) / 2
+	g := (int(c0.G) + int(c1.G)) / 2
+	b := (int(c0.B) + int(c1.B)) / 2
 	return color.RGBA{uint8(r), uint8(g), uint8(b), 255}
 }
 
+// decodeBlockRows splits [0, blockHeight) into parallelism contiguous bands
+// of block rows and runs fn over each band concurrently. Each band owns a
+// disjoint set of pixel rows, so concurrent calls to fn never write the
+// same destination pixels. parallelism <= 1 runs fn synchronously over the
+// whole range.
+func decodeBlockRows(blockHeight, parallelism int, fn func(blockYStart, blockYEnd int)) {
+	if parallelism <= 1 || blockHeight <= 1 {
+		fn(0, blockHeight)
+		return
+	}
+	if parallelism > blockHeight {
+		parallelism = blockHeight
+	}
+
+	rowsPerWorker := (blockHeight + parallelism - 1) / parallelism
+	var wg sync.WaitGroup
+	for start := 0; start < blockHeight; start += rowsPerWorker {
+		end := start + rowsPerWorker
+		if end > blockHeight {
+			end = blockHeight
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			fn(start, end)
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+// Decoder decodes block-compressed DDS surfaces, optionally fanning the
+// per-block work across goroutines. The zero value uses runtime.NumCPU()
+// goroutines; set Parallelism to 1 to decode sequentially.
+type Decoder struct {
+	// Parallelism is the number of goroutines used to decode a surface's
+	// blocks. Zero or negative means runtime.NumCPU().
+	Parallelism int
+}
+
+// Decode reads just the top-level surface of a DDS file, like the package
+// Decode function, but splits block decoding across d.Parallelism
+// goroutines.
+func (d *Decoder) Decode(r io.Reader) (image.Image, error) {
+	p := d.Parallelism
+	if p <= 0 {
+		p = runtime.NumCPU()
+	}
+	return decode(r, p)
+}
+
 // DXT1 ---------------------------------------------------------------------
 
-// decodeDXT1 decodes a DXT1 compressed byte slice into an RGBA image.
-func decodeDXT1(compressed []byte, width, height int) (*image.RGBA, error) {
+// decodeDXT1Parallel decodes a DXT1 compressed byte slice into an RGBA
+// image, with block decoding split across parallelism goroutines, each
+// owning a disjoint band of block rows.
+func decodeDXT1Parallel(compressed []byte, width, height, parallelism int) (*image.RGBA, error) {
 	decompressed := image.NewRGBA(image.Rect(0, 0, width, height))
 
 	blockWidth := (width + 3) / 4
 	blockHeight := (height + 3) / 4
 
-loop:
-	for blockY := 0; blockY < blockHeight; blockY++ {
-		for blockX := 0; blockX < blockWidth; blockX++ {
-			if len(compressed) < (blockY*blockWidth+blockX)*8+8 {
-				break loop
+	decodeBlockRows(blockHeight, parallelism, func(blockYStart, blockYEnd int) {
+		for blockY := blockYStart; blockY < blockYEnd; blockY++ {
+			for blockX := 0; blockX < blockWidth; blockX++ {
+				blockOffset := (blockY*blockWidth + blockX) * 8 // Each DXT1 block is 8 bytes
+				if blockOffset+8 > len(compressed) {
+					return
+				}
+				decodeBlockDXT1(compressed[blockOffset:blockOffset+8], decompressed, blockX*4, blockY*4, width, height)
 			}
-
-			blockOffset := (blockY*blockWidth + blockX) * 8 // Each DXT1 block is 8 bytes
-			decodeBlockDXT1(compressed[blockOffset:blockOffset+8], decompressed, blockX*4, blockY*4, width)
 		}
-	}
+	})
 
 	return decompressed, nil
 }
 
-func decodeBlockDXT1(block []byte, img *image.RGBA, x, y, width int) {
+func decodeBlockDXT1(block []byte, dst *image.RGBA, x, y, width, height int) {
 	c0 := binary.LittleEndian.Uint16(block[0:2])
 	c1 := binary.LittleEndian.Uint16(block[2:4])
 	colorData := binary.LittleEndian.Uint32(block[4:8])
 
-	colors := make([]color.RGBA, 4)
+	var colors [4]color.RGBA
 	colors[0] = rgb565ToRGBAColor(c0)
 	colors[1] = rgb565ToRGBAColor(c1)
 	if c0 > c1 {
@@ -240,52 +252,62 @@ func decodeBlockDXT1(block []byte, img *image.RGBA, x, y, width int) {
 	}
 
 	for j := 0; j < 4; j++ {
+		py := y + j
+		if py >= height {
+			continue
+		}
 		for i := 0; i < 4; i++ {
 			px := x + i
-			py := y + j
 			if px >= width {
 				continue
 			}
 
 			colorIndex := (colorData >> uint((j*4+i)*2)) & 0x3
-			color := colors[colorIndex]
+			c := colors[colorIndex]
 
-			img.Set(px, py, color)
+			o := dst.PixOffset(px, py)
+			dst.Pix[o+0] = c.R
+			dst.Pix[o+1] = c.G
+			dst.Pix[o+2] = c.B
+			dst.Pix[o+3] = c.A
 		}
 	}
 }
 
 // DXT2, DXT3 ---------------------------------------------------------------
 
-// decodeDXT3 decodes a DXT3 (similar to DXT2 but without premultiplied alpha) compressed byte slice into an RGBA image.
-func decodeDXT3(compressed []byte, width, height int) (*image.RGBA, error) {
+// decodeDXT3Parallel decodes a DXT3 (similar to DXT2 but without
+// premultiplied alpha) compressed byte slice into an RGBA image, with
+// block decoding split across parallelism goroutines, each owning a
+// disjoint band of block rows.
+func decodeDXT3Parallel(compressed []byte, width, height, parallelism int) (*image.RGBA, error) {
 	decompressed := image.NewRGBA(image.Rect(0, 0, width, height))
 
 	blockWidth := (width + 3) / 4
 	blockHeight := (height + 3) / 4
 
-loop:
-	for blockY := 0; blockY < blockHeight; blockY++ {
-		for blockX := 0; blockX < blockWidth; blockX++ {
-			if len(compressed) < (blockY*blockWidth+blockX)*16+16 {
-				break loop
+	decodeBlockRows(blockHeight, parallelism, func(blockYStart, blockYEnd int) {
+		for blockY := blockYStart; blockY < blockYEnd; blockY++ {
+			for blockX := 0; blockX < blockWidth; blockX++ {
+				blockOffset := (blockY*blockWidth + blockX) * 16 // Each DXT3 block is 16 bytes
+				if blockOffset+16 > len(compressed) {
+					return
+				}
+				decodeBlockDXT3(compressed[blockOffset:blockOffset+16], decompressed, blockX*4, blockY*4, width, height)
 			}
-
-			blockOffset := (blockY*blockWidth + blockX) * 16 // Each DXT3 block is 16 bytes
-			decodeBlockDXT3(compressed[blockOffset:blockOffset+16], decompressed, blockX*4, blockY*4, width)
 		}
-	}
+	})
 
 	return decompressed, nil
 }
 
-func decodeBlockDXT3(block []byte, img *image.RGBA, x, y, width int) {
+func decodeBlockDXT3(block []byte, dst *image.RGBA, x, y, width, height int) {
 	alphaData := binary.LittleEndian.Uint64(block[0:8])
 	c0 := binary.LittleEndian.Uint16(block[8:10])
 	c1 := binary.LittleEndian.Uint16(block[10:12])
 	colorData := binary.LittleEndian.Uint32(block[12:16])
 
-	colors := make([]color.RGBA, 4)
+	var colors [4]color.RGBA
 	colors[0] = rgb565ToRGBAColor(c0)
 	colors[1] = rgb565ToRGBAColor(c1)
 	if c0 > c1 {
@@ -297,48 +319,57 @@ func decodeBlockDXT3(block []byte, img *image.RGBA, x, y, width int) {
 	}
 
 	for j := 0; j < 4; j++ {
+		py := y + j
+		if py >= height {
+			continue
+		}
 		for i := 0; i < 4; i++ {
 			px := x + i
-			py := y + j
 			if px >= width {
 				continue
 			}
 
 			alpha := uint8((alphaData>>uint(j*16+i*4))&0xF) * 17
 			colorIndex := (colorData >> uint((j*4+i)*2)) & 0x3
-			color := colors[colorIndex]
-			color.A = alpha
-
-			img.Set(px, py, color)
+			c := colors[colorIndex]
+			c.A = alpha
+
+			o := dst.PixOffset(px, py)
+			dst.Pix[o+0] = c.R
+			dst.Pix[o+1] = c.G
+			dst.Pix[o+2] = c.B
+			dst.Pix[o+3] = c.A
 		}
 	}
 }
 
 // DXT4, DXT5 ---------------------------------------------------------------
 
-// decodeDXT5 decodes a DXT5 compressed byte slice into an RGBA image.
-func decodeDXT5(compressed []byte, width, height int) (*image.RGBA, error) {
+// decodeDXT5Parallel decodes a DXT5 compressed byte slice into an RGBA
+// image, with block decoding split across parallelism goroutines, each
+// owning a disjoint band of block rows.
+func decodeDXT5Parallel(compressed []byte, width, height, parallelism int) (*image.RGBA, error) {
 	decompressed := image.NewRGBA(image.Rect(0, 0, width, height))
 
 	blockWidth := (width + 3) / 4
 	blockHeight := (height + 3) / 4
 
-loop:
-	for blockY := 0; blockY < blockHeight; blockY++ {
-		for blockX := 0; blockX < blockWidth; blockX++ {
-			if len(compressed) < (blockY*blockWidth+blockX)*16+16 {
-				break loop
+	decodeBlockRows(blockHeight, parallelism, func(blockYStart, blockYEnd int) {
+		for blockY := blockYStart; blockY < blockYEnd; blockY++ {
+			for blockX := 0; blockX < blockWidth; blockX++ {
+				blockOffset := (blockY*blockWidth + blockX) * 16 // Each DXT5 block is 16 bytes
+				if blockOffset+16 > len(compressed) {
+					return
+				}
+				decodeBlockDXT5(compressed[blockOffset:blockOffset+16], decompressed, blockX*4, blockY*4, width, height)
 			}
-
-			blockOffset := (blockY*blockWidth + blockX) * 16 // Each DXT5 block is 16 bytes
-			decodeBlockDXT5(compressed[blockOffset:blockOffset+16], decompressed, blockX*4, blockY*4, width)
 		}
-	}
+	})
 
 	return decompressed, nil
 }
 
-func decodeBlockDXT5(block []byte, img *image.RGBA, x, y, width int) {
+func decodeBlockDXT5(block []byte, dst *image.RGBA, x, y, width, height int) {
 	alpha0 := block[0]
 	alpha1 := block[1]
 	alphaData := binary.LittleEndian.Uint64(block[0:8]) >> 16
@@ -346,7 +377,7 @@ func decodeBlockDXT5(block []byte, img *image.RGBA, x, y, width int) {
 	c1 := binary.LittleEndian.Uint16(block[10:12])
 	colorData := binary.LittleEndian.Uint32(block[12:16])
 
-	colors := make([]color.RGBA, 4)
+	var colors [4]color.RGBA
 	colors[0] = rgb565ToRGBAColor(c0)
 	colors[1] = rgb565ToRGBAColor(c1)
 	if c0 > c1 {
@@ -358,9 +389,12 @@ func decodeBlockDXT5(block []byte, img *image.RGBA, x, y, width int) {
 	}
 
 	for j := 0; j < 4; j++ {
+		py := y + j
+		if py >= height {
+			continue
+		}
 		for i := 0; i < 4; i++ {
 			px := x + i
-			py := y + j
 			if px >= width {
 				continue
 			}
@@ -411,10 +445,14 @@ func decodeBlockDXT5(block []byte, img *image.RGBA, x, y, width int) {
 			}
 
 			colorIndex := (colorData >> uint((j*4+i)*2)) & 0x3
-			color := colors[colorIndex]
-			color.A = alpha
-
-			img.Set(px, py, color)
+			c := colors[colorIndex]
+			c.A = alpha
+
+			o := dst.PixOffset(px, py)
+			dst.Pix[o+0] = c.R
+			dst.Pix[o+1] = c.G
+			dst.Pix[o+2] = c.B
+			dst.Pix[o+3] = c.A
 		}
 	}
 }
@@ -0,0 +1,115 @@
+/*
+Copyright 2017 Luke Granger-Brown
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// These blocks exercise the exact bit patterns DirectXTex's BC1/BC2/BC3
+// reference decoders produce for a four-color endpoint pair of
+// c0=0xFFFF (white), c1=0x0000 (black), with indices 0,1,2,3 across the
+// first row and index 0 filling the rest of the block. The expected
+// pixels are the S3TC-spec (2*c0+c1+1)/3 and (c0+2*c1+1)/3 interpolants
+// of this package's own (non-bit-replicated) RGB565 expansion.
+var goldenColorBlock = [8]byte{0xFF, 0xFF, 0x00, 0x00, 0xE4, 0x00, 0x00, 0x00}
+
+var goldenColorRow0 = [4]color.RGBA{
+	{248, 252, 248, 255},
+	{0, 0, 0, 255},
+	{165, 168, 165, 255},
+	{83, 84, 83, 255},
+}
+
+func TestDecodeBlockDXT1Golden(t *testing.T) {
+	dst := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	decodeBlockDXT1(goldenColorBlock[:], dst, 0, 0, 4, 4)
+
+	for i, want := range goldenColorRow0 {
+		if got := dst.RGBAAt(i, 0); got != want {
+			t.Errorf("pixel (%d,0) = %+v, want %+v", i, got, want)
+		}
+	}
+	for y := 1; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			want := color.RGBA{248, 252, 248, 255}
+			if got := dst.RGBAAt(x, y); got != want {
+				t.Errorf("pixel (%d,%d) = %+v, want %+v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestDecodeBlockDXT3Golden(t *testing.T) {
+	block := [16]byte{
+		0x50, 0xFA, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // alpha nibbles 0x0,0x5,0xA,0xF then zero
+		0xFF, 0xFF, 0x00, 0x00, 0xE4, 0x00, 0x00, 0x00, // color block identical to the DXT1 golden case
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	decodeBlockDXT3(block[:], dst, 0, 0, 4, 4)
+
+	wantRow0 := [4]color.RGBA{
+		{248, 252, 248, 0},
+		{0, 0, 0, 85},
+		{165, 168, 165, 170},
+		{83, 84, 83, 255},
+	}
+	for i, want := range wantRow0 {
+		if got := dst.RGBAAt(i, 0); got != want {
+			t.Errorf("pixel (%d,0) = %+v, want %+v", i, got, want)
+		}
+	}
+	for y := 1; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			want := color.RGBA{248, 252, 248, 0}
+			if got := dst.RGBAAt(x, y); got != want {
+				t.Errorf("pixel (%d,%d) = %+v, want %+v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestDecodeBlockDXT5Golden(t *testing.T) {
+	block := [16]byte{
+		0x2A, 0x0A, 0x88, 0x0F, 0x00, 0x00, 0x00, 0x00, // alpha0=42, alpha1=10, codes 0,1,6,7 then zero
+		0xFF, 0xFF, 0x00, 0x00, 0xE4, 0x00, 0x00, 0x00, // color block identical to the DXT1 golden case
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	decodeBlockDXT5(block[:], dst, 0, 0, 4, 4)
+
+	wantRow0 := [4]color.RGBA{
+		{248, 252, 248, 42},
+		{0, 0, 0, 10},
+		{165, 168, 165, 19},
+		{83, 84, 83, 14},
+	}
+	for i, want := range wantRow0 {
+		if got := dst.RGBAAt(i, 0); got != want {
+			t.Errorf("pixel (%d,0) = %+v, want %+v", i, got, want)
+		}
+	}
+	for y := 1; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			want := color.RGBA{248, 252, 248, 42}
+			if got := dst.RGBAAt(x, y); got != want {
+				t.Errorf("pixel (%d,%d) = %+v, want %+v", x, y, got, want)
+			}
+		}
+	}
+}
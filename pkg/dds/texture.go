@@ -0,0 +1,309 @@
+/*
+Copyright 2017 Luke Granger-Brown
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"fmt"
+	"image"
+	"io"
+)
+
+// Dimension identifies the shape of a Texture's surfaces.
+type Dimension int
+
+const (
+	// Dimension1D is a single row of texels (depth == height == 1).
+	Dimension1D Dimension = iota
+	// Dimension2D is a conventional flat texture.
+	Dimension2D
+	// Dimension3D is a volume texture, depth > 1.
+	Dimension3D
+	// DimensionCube is six 2D faces forming a cubemap.
+	DimensionCube
+)
+
+// CubeFace identifies one of the six faces of a cubemap, in the order
+// DDSCAPS2_CUBEMAP_POSITIVEX..NEGATIVEZ mandates them appear in the
+// file.
+type CubeFace int
+
+const (
+	CubeFacePositiveX CubeFace = iota
+	CubeFaceNegativeX
+	CubeFacePositiveY
+	CubeFaceNegativeY
+	CubeFacePositiveZ
+	CubeFaceNegativeZ
+)
+
+// Faces returns the six cubemap face orientations in on-disk order.
+func Faces() [6]CubeFace {
+	return [6]CubeFace{
+		CubeFacePositiveX, CubeFaceNegativeX,
+		CubeFacePositiveY, CubeFaceNegativeY,
+		CubeFacePositiveZ, CubeFaceNegativeZ,
+	}
+}
+
+// surfaceFormat is the decoded pixel format of every surface in a
+// Texture; DecodeAll only supports files whose surfaces are all the
+// same format, as the DDS spec requires.
+type surfaceFormat struct {
+	fourCC     uint32
+	dxgiFormat DXGIFormat
+	isDXT10    bool
+	width      uint32
+	height     uint32
+	pixelFmt   pixelFormat
+}
+
+// Texture is the fully decoded contents of a DDS file: every mip level
+// of every face of every array slice, and every depth slice of every
+// mip level for a Dimension3D volume texture.
+type Texture struct {
+	Dimension Dimension
+	ArraySize int
+	MipCount  int
+	// Depth is the number of depth slices at mip 0. It is 1 for every
+	// Dimension other than Dimension3D.
+	Depth int
+
+	surfaces [][6][][]image.Image // [arrayIdx][face][mip][depthSlice]
+}
+
+// Surface returns the decoded image for the given array slice, cubemap
+// face (0 for non-cubemaps), and mip level. For a Dimension3D volume
+// texture this is the first (z=0) depth slice; use SurfaceDepth to
+// address the others.
+func (t *Texture) Surface(arrayIdx, face, mip int) (image.Image, error) {
+	return t.SurfaceDepth(arrayIdx, face, mip, 0)
+}
+
+// SurfaceDepth returns the decoded image for the given array slice,
+// cubemap face (0 for non-cubemaps), mip level, and depth slice. depth
+// must be 0 for every Dimension other than Dimension3D, where mip level
+// m holds max(1, Depth>>m) slices.
+func (t *Texture) SurfaceDepth(arrayIdx, face, mip, depth int) (image.Image, error) {
+	if arrayIdx < 0 || arrayIdx >= len(t.surfaces) {
+		return nil, fmt.Errorf("dds: array index %d out of range [0,%d)", arrayIdx, len(t.surfaces))
+	}
+	if face < 0 || face >= 6 {
+		return nil, fmt.Errorf("dds: face index %d out of range [0,6)", face)
+	}
+	mips := t.surfaces[arrayIdx][face]
+	if mip < 0 || mip >= len(mips) {
+		return nil, fmt.Errorf("dds: mip level %d out of range [0,%d)", mip, len(mips))
+	}
+	slices := mips[mip]
+	if depth < 0 || depth >= len(slices) {
+		return nil, fmt.Errorf("dds: depth slice %d out of range [0,%d)", depth, len(slices))
+	}
+	return slices[depth], nil
+}
+
+// DecodeAll reads every surface (array slices x cubemap faces x mips)
+// out of a DDS stream and returns them as a Texture. Callers that only
+// want the top-level 2D surface can keep using Decode.
+func DecodeAll(r io.Reader) (*Texture, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	fmtInfo, dx10, err := readSurfaceFormat(r, h)
+	if err != nil {
+		return nil, err
+	}
+
+	arraySize := 1
+	isCubemap := h.caps2&caps2Cubemap == caps2Cubemap
+	if fmtInfo.isDXT10 {
+		if dx10.IsCubemap() {
+			isCubemap = true
+		}
+		if dx10.arraySize > 0 {
+			arraySize = int(dx10.arraySize)
+		}
+	}
+
+	isVolume := h.caps2&caps2Volume == caps2Volume && h.depth > 1
+
+	dimension := Dimension2D
+	switch {
+	case isCubemap:
+		dimension = DimensionCube
+	case isVolume:
+		dimension = Dimension3D
+	}
+
+	mipCount := int(h.mipMapCount)
+	if mipCount == 0 {
+		mipCount = 1
+	}
+
+	numFaces := 1
+	if isCubemap {
+		numFaces = 6
+	}
+
+	depth := 1
+	if isVolume {
+		depth = int(h.depth)
+	}
+
+	t := &Texture{
+		Dimension: dimension,
+		ArraySize: arraySize,
+		MipCount:  mipCount,
+		Depth:     depth,
+		surfaces:  make([][6][][]image.Image, arraySize),
+	}
+
+	for a := 0; a < arraySize; a++ {
+		for f := 0; f < numFaces; f++ {
+			mips := make([][]image.Image, mipCount)
+			width, height := int(h.width), int(h.height)
+			for m := 0; m < mipCount; m++ {
+				mw, mh := max(1, width>>uint(m)), max(1, height>>uint(m))
+				mipDepth := max(1, depth>>uint(m))
+				slices := make([]image.Image, mipDepth)
+				for d := 0; d < mipDepth; d++ {
+					img, _, err := decodeSurface(r, fmtInfo, mw, mh, 1)
+					if err != nil {
+						return nil, fmt.Errorf("decoding array %d face %d mip %d depth %d: %v", a, f, m, d, err)
+					}
+					slices[d] = img
+				}
+				mips[m] = slices
+			}
+			t.surfaces[a][f] = mips
+		}
+	}
+
+	return t, nil
+}
+
+// readSurfaceFormat reads the optional DDS_HEADER_DXT10 extension (when
+// h.pixelFormat.fourCC is "DX10") and builds the surfaceFormat that
+// decodeSurface needs to decode every surface in the file. Decode and
+// DecodeAll both call this, so a future change to DX10 header handling
+// can't land in only one of the two paths.
+func readSurfaceFormat(r io.Reader, h header) (surfaceFormat, headerDXT10, error) {
+	fmtInfo := surfaceFormat{
+		fourCC:   h.pixelFormat.fourCC,
+		width:    h.width,
+		height:   h.height,
+		pixelFmt: h.pixelFormat,
+	}
+
+	var dx10 headerDXT10
+	if h.pixelFormat.flags&pfFourCC == pfFourCC && h.pixelFormat.fourCC == fourCCDX10 {
+		var err error
+		dx10, err = readHeaderDXT10(r)
+		if err != nil {
+			return surfaceFormat{}, headerDXT10{}, fmt.Errorf("reading DXT10 header: %v", err)
+		}
+		fmtInfo.isDXT10 = true
+		fmtInfo.dxgiFormat = dx10.dxgiFormat
+	}
+
+	return fmtInfo, dx10, nil
+}
+
+// decodeSurface reads and decodes a single width x height surface from
+// r, using fmtInfo to select an uncompressed or BCn codec, and returns
+// the number of bytes it consumed. parallelism is forwarded to codecs
+// that support splitting block decoding across goroutines (currently
+// DXT1/2/3/4/5); BC4-7 always decode sequentially.
+func decodeSurface(r io.Reader, fmtInfo surfaceFormat, width, height, parallelism int) (image.Image, int, error) {
+	if fmtInfo.isDXT10 {
+		blockSize, err := blockSizeForDXGIFormat(fmtInfo.dxgiFormat)
+		if err != nil {
+			return nil, 0, err
+		}
+		blockWidth := (width + 3) / 4
+		blockHeight := (height + 3) / 4
+		n := blockWidth * blockHeight * blockSize
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, 0, fmt.Errorf("reading compressed data: %v", err)
+		}
+		img, err := decodeDXGIFormat(fmtInfo.dxgiFormat, buf, width, height)
+		return img, n, err
+	}
+
+	if fmtInfo.pixelFmt.flags&pfFourCC == pfFourCC {
+		blockSize, decode, err := blockCodecForFourCC(fmtInfo.pixelFmt.fourCC, parallelism)
+		if err != nil {
+			return nil, 0, err
+		}
+		blockWidth := (width + 3) / 4
+		blockHeight := (height + 3) / 4
+		n := blockWidth * blockHeight * blockSize
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, 0, fmt.Errorf("reading compressed data: %v", err)
+		}
+		img, err := decode(buf, width, height)
+		return img, n, err
+	}
+
+	pitch := (uint32(width)*fmtInfo.pixelFmt.rgbBitCount + 7) / 8
+	n := int(pitch) * height
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, 0, fmt.Errorf("reading image: %v", err)
+	}
+	stride := fmtInfo.pixelFmt.rgbBitCount / 8
+	return &img{
+		h: header{
+			width:       uint32(width),
+			height:      uint32(height),
+			pixelFormat: fmtInfo.pixelFmt,
+		},
+		buf:    buf,
+		pitch:  int(pitch),
+		stride: int(stride),
+		rBit:   lowestSetBit(fmtInfo.pixelFmt.rBitMask),
+		gBit:   lowestSetBit(fmtInfo.pixelFmt.gBitMask),
+		bBit:   lowestSetBit(fmtInfo.pixelFmt.bBitMask),
+		aBit:   lowestSetBit(fmtInfo.pixelFmt.aBitMask),
+	}, n, nil
+}
+
+// blockCodecForFourCC returns the block byte size and decode function
+// for a legacy (non-DX10) block-compressed FourCC, splitting block
+// decoding across parallelism goroutines.
+func blockCodecForFourCC(fourCC uint32, parallelism int) (int, func([]byte, int, int) (image.Image, error), error) {
+	switch fourCC {
+	case fourCCDXT1:
+		return 8, func(b []byte, w, h int) (image.Image, error) { return decodeDXT1Parallel(b, w, h, parallelism) }, nil
+	case fourCCDXT2, fourCCDXT3:
+		return 16, func(b []byte, w, h int) (image.Image, error) { return decodeDXT3Parallel(b, w, h, parallelism) }, nil
+	case fourCCDXT4, fourCCDXT5:
+		return 16, func(b []byte, w, h int) (image.Image, error) { return decodeDXT5Parallel(b, w, h, parallelism) }, nil
+	default:
+		return 0, nil, fmt.Errorf("dds: unsupported FourCC %#x", fourCC)
+	}
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
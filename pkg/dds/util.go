@@ -0,0 +1,42 @@
+/*
+Copyright 2017 Luke Granger-Brown
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+// lowestSetBit returns the bit index of the lowest set bit in mask, or 0
+// if mask is zero.
+func lowestSetBit(mask uint32) uint {
+	if mask == 0 {
+		return 0
+	}
+	var n uint
+	for mask&1 == 0 {
+		mask >>= 1
+		n++
+	}
+	return n
+}
+
+// readBits reads the low bitCount/8 bytes of buf as a little-endian
+// unsigned integer.
+func readBits(buf []byte, bitCount uint32) uint32 {
+	var v uint32
+	n := bitCount / 8
+	for i := uint32(0); i < n && int(i) < len(buf); i++ {
+		v |= uint32(buf[i]) << (8 * i)
+	}
+	return v
+}